@@ -9,6 +9,7 @@ import (
 
 	"awesomeProject3/internal/domain/repository"
 	"awesomeProject3/internal/pubsub/delivery/grpc"
+	"awesomeProject3/internal/subpub"
 	"awesomeProject3/internal/usecase/publish"
 	"awesomeProject3/internal/usecase/subscribe"
 	"awesomeProject3/pkg/config"
@@ -32,14 +33,17 @@ func main() {
 	}
 
 	// Create repository
-	eventRepo := repository.NewInMemoryRepository()
+	eventRepo := repository.NewInMemoryRepository(
+		subpub.WithLogger(log),
+		subpub.WithQueueSize(cfg.PubSub.MessageBufferSize),
+	)
 
 	// Create use cases
 	publishUC := publish.New(eventRepo, log)
 	subscribeUC := subscribe.New(eventRepo, log)
 
 	// Create gRPC handler with use cases
-	handler := grpc.NewHandler(log, publishUC, subscribeUC)
+	handler := grpc.NewHandler(log, publishUC, subscribeUC, cfg.PubSub)
 
 	// Create and start gRPC server
 	server := grpc.NewServer(handler, log, cfg.Server.Port)
@@ -59,8 +63,11 @@ func main() {
 	<-ctx.Done()
 
 	// Graceful shutdown
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.GracefulShutdownTimeout.Duration)
+	defer cancel()
+
 	server.Stop()
-	if err := eventRepo.Close(context.Background()); err != nil {
+	if err := eventRepo.Close(shutdownCtx); err != nil {
 		log.WithError(err).Error("failed to close repository")
 	}
 }
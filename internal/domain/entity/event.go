@@ -11,6 +11,7 @@ type Event struct {
 	ID        string
 	Key       string
 	Data      string
+	Metadata  map[string]string
 	Timestamp time.Time
 }
 
@@ -19,8 +19,9 @@ func (e *DomainError) Error() string {
 
 // Common domain errors
 var (
-	// ErrInvalidEventKey is returned when an event key is empty
-	ErrInvalidEventKey = errors.New("invalid event key: key cannot be empty")
+	// ErrInvalidEventKey is returned when an event key is empty or is not
+	// a dot-separated subject (wildcards are allowed only when subscribing)
+	ErrInvalidEventKey = errors.New("invalid event key")
 
 	// ErrInvalidEventData is returned when event data is empty
 	ErrInvalidEventData = errors.New("invalid event data: data cannot be empty")
@@ -30,4 +31,7 @@ var (
 
 	// ErrServiceClosed is returned when trying to use a closed service
 	ErrServiceClosed = errors.New("service is closed")
+
+	// ErrInvalidFilter is returned when a subscription filter expression cannot be parsed
+	ErrInvalidFilter = errors.New("invalid filter expression")
 )
@@ -0,0 +1,194 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"awesomeProject3/internal/domain/entity"
+)
+
+// Filter — разобранное выражение фильтра событий.
+//
+// Выражение состоит из сравнений вида `поле оператор "строка"`, объединённых
+// операторами &&, || и !, и скобок. Поля: data, key и metadata.<имя>
+// (отсутствующее значение метаданных считается пустой строкой).
+// Операторы сравнения: == и != (равенство), contains (подстрока),
+// matches (регулярное выражение RE2). Например:
+//
+//	metadata.region == "eu" && (data contains "urgent" || !(key matches "^test\\."))
+type Filter struct {
+	expr  string
+	match func(*entity.Event) bool
+}
+
+// Parse разбирает выражение фильтра.
+func Parse(expr string) (*Filter, error) {
+	p := &parser{lexer: lexer{input: expr}}
+	p.next()
+
+	match, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokenEOF {
+		return nil, p.errorf("unexpected %s", p.tok)
+	}
+	return &Filter{expr: expr, match: match}, nil
+}
+
+// Match сообщает, подходит ли событие под выражение.
+func (f *Filter) Match(event *entity.Event) bool {
+	return f.match(event)
+}
+
+func (f *Filter) String() string {
+	return f.expr
+}
+
+// SyntaxError описывает ошибку разбора выражения.
+type SyntaxError struct {
+	// Pos — смещение в байтах от начала выражения
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("filter: %s at position %d", e.Msg, e.Pos)
+}
+
+type predicate = func(*entity.Event) bool
+
+type parser struct {
+	lexer
+	tok token
+}
+
+func (p *parser) next() {
+	p.tok = p.lexer.next()
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return &SyntaxError{Pos: p.tok.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// parseOr разбирает дизъюнкцию: and { "||" and }.
+func (p *parser) parseOr() (predicate, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tokenOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(e *entity.Event) bool { return l(e) || right(e) }
+	}
+	return left, nil
+}
+
+// parseAnd разбирает конъюнкцию: not { "&&" not }.
+func (p *parser) parseAnd() (predicate, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tokenAnd {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(e *entity.Event) bool { return l(e) && right(e) }
+	}
+	return left, nil
+}
+
+// parseNot разбирает отрицание, выражение в скобках или сравнение.
+func (p *parser) parseNot() (predicate, error) {
+	switch p.tok.kind {
+	case tokenNot:
+		p.next()
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return func(e *entity.Event) bool { return !operand(e) }, nil
+
+	case tokenLParen:
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokenRParen {
+			return nil, p.errorf("expected ), got %s", p.tok)
+		}
+		p.next()
+		return inner, nil
+
+	default:
+		return p.parseComparison()
+	}
+}
+
+// parseComparison разбирает сравнение: поле оператор "строка".
+func (p *parser) parseComparison() (predicate, error) {
+	if p.tok.kind != tokenIdent {
+		return nil, p.errorf("expected field, got %s", p.tok)
+	}
+	field, err := p.parseField(p.tok.text)
+	if err != nil {
+		return nil, err
+	}
+	p.next()
+
+	op := p.tok
+	switch {
+	case op.kind == tokenEq, op.kind == tokenNe:
+	case op.kind == tokenIdent && (op.text == "contains" || op.text == "matches"):
+	default:
+		return nil, p.errorf("expected operator, got %s", op)
+	}
+	p.next()
+
+	if p.tok.kind != tokenString {
+		return nil, p.errorf("expected string, got %s", p.tok)
+	}
+	value := p.tok.text
+	valuePos := p.tok.pos
+	p.next()
+
+	switch {
+	case op.kind == tokenEq:
+		return func(e *entity.Event) bool { return field(e) == value }, nil
+	case op.kind == tokenNe:
+		return func(e *entity.Event) bool { return field(e) != value }, nil
+	case op.text == "contains":
+		return func(e *entity.Event) bool { return strings.Contains(field(e), value) }, nil
+	default:
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, &SyntaxError{Pos: valuePos, Msg: fmt.Sprintf("invalid regular expression: %v", err)}
+		}
+		return func(e *entity.Event) bool { return re.MatchString(field(e)) }, nil
+	}
+}
+
+func (p *parser) parseField(name string) (func(*entity.Event) string, error) {
+	switch name {
+	case "data":
+		return func(e *entity.Event) string { return e.Data }, nil
+	case "key":
+		return func(e *entity.Event) string { return e.Key }, nil
+	}
+
+	if key, ok := strings.CutPrefix(name, "metadata."); ok && key != "" {
+		return func(e *entity.Event) string { return e.Metadata[key] }, nil
+	}
+	return nil, p.errorf("unknown field %q", name)
+}
@@ -0,0 +1,132 @@
+package filter
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"awesomeProject3/internal/domain/entity"
+)
+
+func TestFilterMatch(t *testing.T) {
+	event := &entity.Event{
+		Key:  "orders.eu",
+		Data: `urgent "order"`,
+		Metadata: map[string]string{
+			"region": "eu",
+			"tier":   "gold",
+			"city":   "München",
+		},
+	}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		// Операторы сравнения
+		{`key == "orders.eu"`, true},
+		{`key == "orders"`, false},
+		{`key != "orders.eu"`, false},
+		{`key != "orders"`, true},
+		{`data contains "urgent"`, true},
+		{`data contains "later"`, false},
+		{`key matches "^orders\\.[a-z]+$"`, true},
+		{`key matches "^users\\."`, false},
+		{"\tkey==\"orders.eu\"\n", true},
+
+		// && связывает сильнее ||
+		{`key == "orders.eu" || key == "x" && data == "x"`, true},
+		{`data == "x" && key == "x" || metadata.region == "eu"`, true},
+		{`key == "x" && data == "x" || key == "x"`, false},
+		{`(key == "orders.eu" || key == "x") && data == "x"`, false},
+		{`key == "orders.eu" && (data == "x" || metadata.tier == "gold")`, true},
+
+		// Отрицание и скобки
+		{`!key == "x"`, true},
+		{`!(key == "orders.eu")`, false},
+		{`!!key == "orders.eu"`, true},
+		{`!(data == "x") && metadata.tier == "gold"`, true},
+		{`!(key == "orders.eu" && metadata.region == "eu")`, false},
+		{`((key == "orders.eu"))`, true},
+
+		// Экранирование в строках
+		{`data == "urgent \"order\""`, true},
+		{`data contains "\"order\""`, true},
+		{`metadata.city == "München"`, true},
+		{`data matches "\"order\"$"`, true},
+
+		// Отсутствующие метаданные считаются пустой строкой
+		{`metadata.missing == ""`, true},
+		{`metadata.missing != ""`, false},
+		{`metadata.missing matches "^$"`, true},
+		{`metadata.missing contains "eu"`, false},
+		{`!(metadata.missing contains "eu")`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			f, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse(%q) failed: %v", tt.expr, err)
+			}
+			if got := f.Match(event); got != tt.want {
+				t.Errorf("Match = %v, want %v", got, tt.want)
+			}
+			if f.String() != tt.expr {
+				t.Errorf("String() = %q, want %q", f.String(), tt.expr)
+			}
+		})
+	}
+}
+
+func TestFilterMatchNilMetadata(t *testing.T) {
+	f, err := Parse(`metadata.region == "" && key == "orders"`)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if !f.Match(&entity.Event{Key: "orders"}) {
+		t.Error("event without metadata should match empty metadata value")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		pos  int
+		msg  string
+	}{
+		{``, 0, "expected field"},
+		{`!`, 1, "expected field"},
+		{`key`, 3, "expected operator"},
+		{`key = "a"`, 4, "expected operator"},
+		{`key like "a"`, 4, "expected operator"},
+		{`key ==`, 6, "expected string"},
+		{`key == 'a'`, 7, "expected string"},
+		{`key == "abc`, 7, "expected string"},
+		{`key == "\q"`, 7, "expected string"},
+		{`key == "a" &&`, 13, "expected field"},
+		{`key == "a" key == "b"`, 11, "unexpected"},
+		{`key == "a")`, 10, "unexpected"},
+		{`(key == "a"`, 11, "expected )"},
+		{`size == "1"`, 0, "unknown field"},
+		{`metadata. == "1"`, 0, "unknown field"},
+		{`key matches "("`, 12, "invalid regular expression"},
+		{`key == "a" && data matches "a(b"`, 27, "invalid regular expression"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := Parse(tt.expr)
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("Parse(%q): got %v, want *SyntaxError", tt.expr, err)
+			}
+			if syntaxErr.Pos != tt.pos {
+				t.Errorf("Pos = %d, want %d (%v)", syntaxErr.Pos, tt.pos, err)
+			}
+			if !strings.Contains(syntaxErr.Msg, tt.msg) {
+				t.Errorf("Msg = %q, want it to contain %q", syntaxErr.Msg, tt.msg)
+			}
+		})
+	}
+}
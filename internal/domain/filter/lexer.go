@@ -0,0 +1,119 @@
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIllegal
+	tokenIdent
+	tokenString
+	tokenEq
+	tokenNe
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenLParen
+	tokenRParen
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of expression"
+	case tokenString:
+		return strconv.Quote(t.text)
+	default:
+		return fmt.Sprintf("%q", t.text)
+	}
+}
+
+// lexer разбивает выражение фильтра на токены.
+type lexer struct {
+	input string
+	pos   int
+}
+
+func (l *lexer) next() token {
+	for l.pos < len(l.input) && isSpace(l.input[l.pos]) {
+		l.pos++
+	}
+	start := l.pos
+	if start == len(l.input) {
+		return token{kind: tokenEOF, pos: start}
+	}
+
+	for _, op := range operators {
+		if strings.HasPrefix(l.input[start:], op.text) {
+			l.pos += len(op.text)
+			return token{kind: op.kind, text: op.text, pos: start}
+		}
+	}
+
+	switch c := l.input[start]; {
+	case c == '"':
+		return l.string()
+	case isIdent(c):
+		for l.pos < len(l.input) && isIdent(l.input[l.pos]) {
+			l.pos++
+		}
+		return token{kind: tokenIdent, text: l.input[start:l.pos], pos: start}
+	default:
+		l.pos = len(l.input)
+		return token{kind: tokenIllegal, text: string(c), pos: start}
+	}
+}
+
+// operators упорядочены так, что двухсимвольные операторы проверяются раньше "!".
+var operators = []struct {
+	text string
+	kind tokenKind
+}{
+	{"==", tokenEq},
+	{"!=", tokenNe},
+	{"&&", tokenAnd},
+	{"||", tokenOr},
+	{"!", tokenNot},
+	{"(", tokenLParen},
+	{")", tokenRParen},
+}
+
+// string читает строковый литерал в двойных кавычках с экранированием как в Go.
+func (l *lexer) string() token {
+	start := l.pos
+	for i := start + 1; i < len(l.input); i++ {
+		switch l.input[i] {
+		case '\\':
+			i++
+		case '"':
+			l.pos = i + 1
+			value, err := strconv.Unquote(l.input[start:l.pos])
+			if err != nil {
+				return token{kind: tokenIllegal, text: l.input[start:l.pos], pos: start}
+			}
+			return token{kind: tokenString, text: value, pos: start}
+		}
+	}
+	l.pos = len(l.input)
+	return token{kind: tokenIllegal, text: l.input[start:], pos: start}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isIdent(c byte) bool {
+	return c == '_' || c == '.' || c == '-' ||
+		'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
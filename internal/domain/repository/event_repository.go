@@ -2,10 +2,55 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"awesomeProject3/internal/domain/entity"
 )
 
+// EventFilter reports whether an event should be delivered to a subscriber
+type EventFilter func(*entity.Event) bool
+
+// OverflowPolicy is what happens to an event when a subscriber queue is full
+type OverflowPolicy int
+
+const (
+	// DropNewest drops the incoming event
+	DropNewest OverflowPolicy = iota
+	// DropOldest drops the oldest queued event to make room for the incoming one
+	DropOldest
+	// Block makes Save wait for queue space up to SubscribeOptions.BlockTimeout,
+	// then drops the incoming event
+	Block
+	// Disconnect drops the incoming event and removes the subscription
+	Disconnect
+)
+
+// SubscribeOptions configures delivery to a single subscriber
+type SubscribeOptions struct {
+	// Filter rejects events the subscriber is not interested in; nil accepts all events
+	Filter EventFilter
+
+	// RateLimit caps delivery at RateLimit events per second with bursts of up to Burst events;
+	// zero disables the limit
+	RateLimit float64
+	Burst     int
+
+	// MaxInFlight is the maximum number of events handled concurrently; zero means one at a time
+	MaxInFlight int
+
+	// BufferSize is the number of events queued for the subscriber while it is busy
+	// or rate-limited; zero uses the repository default
+	BufferSize int
+	// OverflowPolicy is what happens to an event when the subscriber queue is full
+	OverflowPolicy OverflowPolicy
+	// BlockTimeout is how long Save waits for queue space with the Block policy;
+	// zero uses the repository default
+	BlockTimeout time.Duration
+	// OnDrop is called for every event dropped because the subscriber queue is full.
+	// With the Disconnect policy the subscription is removed after the call.
+	OnDrop func(event *entity.Event, policy OverflowPolicy)
+}
+
 // EventRepository defines the interface for event storage
 type EventRepository interface {
 	// Save saves an event to the repository
@@ -15,7 +60,7 @@ type EventRepository interface {
 	FindByKey(ctx context.Context, key string) ([]*entity.Event, error)
 
 	// Subscribe subscribes to events for a given key
-	Subscribe(ctx context.Context, key string, handler func(*entity.Event)) error
+	Subscribe(ctx context.Context, key string, opts SubscribeOptions, handler func(*entity.Event)) error
 
 	// Unsubscribe unsubscribes from events for a given key
 	Unsubscribe(ctx context.Context, key string) error
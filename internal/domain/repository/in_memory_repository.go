@@ -6,46 +6,75 @@ import (
 
 	"awesomeProject3/internal/domain/entity"
 	"awesomeProject3/internal/domain/errors"
+	"awesomeProject3/internal/subpub"
 )
 
-// InMemoryRepository implements EventRepository interface using in-memory storage
+// InMemoryRepository implements EventRepository interface using in-memory storage.
+// Events are delivered to subscribers through a subpub bus: Save only stores
+// the event and enqueues it, so a slow subscriber never blocks publishers or the store.
 type InMemoryRepository struct {
-	mu          sync.RWMutex
-	events      map[string][]*entity.Event
-	subscribers map[string][]func(*entity.Event)
-	closed      bool
+	mu            sync.RWMutex
+	events        map[string][]*entity.Event
+	subscriptions map[string][]subpub.Subscription
+	closed        bool
+
+	bus subpub.SubPub
 }
 
-// NewInMemoryRepository creates a new in-memory repository
-func NewInMemoryRepository() *InMemoryRepository {
+// NewInMemoryRepository creates a new in-memory repository.
+// Options configure the underlying subpub bus.
+func NewInMemoryRepository(opts ...subpub.Option) *InMemoryRepository {
 	return &InMemoryRepository{
-		events:      make(map[string][]*entity.Event),
-		subscribers: make(map[string][]func(*entity.Event)),
+		events:        make(map[string][]*entity.Event),
+		subscriptions: make(map[string][]subpub.Subscription),
+		bus:           subpub.NewSubPub(opts...),
 	}
 }
 
-// Save saves an event to the repository
+// Save saves an event to the repository and publishes it to subscribers of its key
 func (r *InMemoryRepository) Save(ctx context.Context, event *entity.Event) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+	if subpub.ValidateSubject(event.Key) != nil {
+		return errors.ErrInvalidEventKey
+	}
 
+	r.mu.Lock()
 	if r.closed {
+		r.mu.Unlock()
 		return errors.ErrServiceClosed
 	}
-
-	// Save event
 	r.events[event.Key] = append(r.events[event.Key], event)
-
-	// Notify subscribers
-	if subscribers, ok := r.subscribers[event.Key]; ok {
-		for _, handler := range subscribers {
-			handler(event)
-		}
+	r.mu.Unlock()
+
+	// Fan-out happens outside the store lock
+	if err := r.bus.Publish(event.Key, event); err != nil {
+		// Close may have run after the event was stored: the caller gets
+		// an error, so the event must not stay in the store
+		r.discard(event)
+		return mapBusError(err)
 	}
 
 	return nil
 }
 
+// discard removes an event stored by a Save that failed to publish it
+func (r *InMemoryRepository) discard(event *entity.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	events := r.events[event.Key]
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i] == event {
+			events = append(events[:i:i], events[i+1:]...)
+			break
+		}
+	}
+	if len(events) == 0 {
+		delete(r.events, event.Key)
+	} else {
+		r.events[event.Key] = events
+	}
+}
+
 // FindByKey finds all events for a given key
 func (r *InMemoryRepository) FindByKey(ctx context.Context, key string) ([]*entity.Event, error) {
 	r.mu.RLock()
@@ -63,8 +92,18 @@ func (r *InMemoryRepository) FindByKey(ctx context.Context, key string) ([]*enti
 	return events, nil
 }
 
-// Subscribe subscribes to events for a given key
-func (r *InMemoryRepository) Subscribe(ctx context.Context, key string, handler func(*entity.Event)) error {
+// Subscribe subscribes to events for a given key. The key may be a subpub
+// pattern with "*" and ">" wildcards. Handlers are called asynchronously,
+// one event at a time and in publish order unless opts allow concurrent
+// handling. Events rejected by the filter are never queued for the subscriber;
+// events that do not fit into the subscriber queue are handled according to
+// opts.OverflowPolicy and reported to opts.OnDrop.
+// The subscription is removed once ctx is done.
+func (r *InMemoryRepository) Subscribe(ctx context.Context, key string, opts SubscribeOptions, handler func(*entity.Event)) error {
+	if key == "" {
+		return errors.ErrInvalidEventKey
+	}
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -72,36 +111,118 @@ func (r *InMemoryRepository) Subscribe(ctx context.Context, key string, handler
 		return errors.ErrServiceClosed
 	}
 
-	if key == "" {
-		return errors.ErrInvalidEventKey
+	sub, err := r.bus.Subscribe(key, func(msg interface{}) {
+		handler(msg.(*entity.Event))
+	}, opts.subpubOptions()...)
+	if err != nil {
+		return mapBusError(err)
 	}
+	r.subscriptions[key] = append(r.subscriptions[key], sub)
 
-	r.subscribers[key] = append(r.subscribers[key], handler)
+	context.AfterFunc(ctx, func() {
+		r.remove(key, sub)
+	})
 	return nil
 }
 
+// remove cancels a single subscription when its context is done
+func (r *InMemoryRepository) remove(key string, sub subpub.Subscription) {
+	r.mu.Lock()
+	subs := r.subscriptions[key]
+	for i, s := range subs {
+		if s == sub {
+			subs = append(subs[:i], subs[i+1:]...)
+			break
+		}
+	}
+	if len(subs) == 0 {
+		delete(r.subscriptions, key)
+	} else {
+		r.subscriptions[key] = subs
+	}
+	r.mu.Unlock()
+
+	sub.Unsubscribe()
+}
+
 // Unsubscribe unsubscribes from events for a given key
 func (r *InMemoryRepository) Unsubscribe(ctx context.Context, key string) error {
 	r.mu.Lock()
-	defer r.mu.Unlock()
-
 	if r.closed {
+		r.mu.Unlock()
 		return errors.ErrServiceClosed
 	}
+	subs := r.subscriptions[key]
+	delete(r.subscriptions, key)
+	r.mu.Unlock()
 
-	delete(r.subscribers, key)
+	for _, sub := range subs {
+		sub.Unsubscribe()
+	}
 	return nil
 }
 
-// Close closes the repository
+// Close closes the repository and waits until already published events
+// are delivered or ctx is done
 func (r *InMemoryRepository) Close(ctx context.Context) error {
 	r.mu.Lock()
-	defer r.mu.Unlock()
-
 	if r.closed {
+		r.mu.Unlock()
 		return nil
 	}
-
 	r.closed = true
-	return nil
+	r.subscriptions = make(map[string][]subpub.Subscription)
+	r.mu.Unlock()
+
+	return r.bus.Close(ctx)
+}
+
+// mapBusError converts subpub errors to domain errors
+func mapBusError(err error) error {
+	switch err {
+	case subpub.ErrClosed:
+		return errors.ErrServiceClosed
+	case subpub.ErrInvalidSubject, subpub.ErrWildcardSubject:
+		return errors.ErrInvalidEventKey
+	default:
+		return err
+	}
+}
+
+// busPolicies maps overflow policies to their subpub counterparts
+var busPolicies = map[OverflowPolicy]subpub.OverflowPolicy{
+	DropNewest: subpub.DropNewest,
+	DropOldest: subpub.DropOldest,
+	Block:      subpub.Block,
+	Disconnect: subpub.Disconnect,
+}
+
+// subpubOptions converts subscription options to subpub options
+func (o SubscribeOptions) subpubOptions() []subpub.SubscribeOption {
+	var opts []subpub.SubscribeOption
+	if filter := o.Filter; filter != nil {
+		opts = append(opts, subpub.WithFilter(func(_ string, msg interface{}) bool {
+			return filter(msg.(*entity.Event))
+		}))
+	}
+	if o.RateLimit > 0 {
+		opts = append(opts, subpub.WithRateLimit(o.RateLimit, o.Burst))
+	}
+	if o.MaxInFlight > 0 {
+		opts = append(opts, subpub.WithMaxInFlight(o.MaxInFlight))
+	}
+	opts = append(opts, subpub.WithOverflowPolicy(busPolicies[o.OverflowPolicy]))
+	if o.BufferSize > 0 {
+		opts = append(opts, subpub.WithBufferSize(o.BufferSize))
+	}
+	if o.BlockTimeout > 0 {
+		opts = append(opts, subpub.WithBlockTimeout(o.BlockTimeout))
+	}
+	if onDrop := o.OnDrop; onDrop != nil {
+		policy := o.OverflowPolicy
+		opts = append(opts, subpub.WithSubscriptionDropHandler(func(_ string, msg interface{}, _ subpub.OverflowPolicy) {
+			onDrop(msg.(*entity.Event), policy)
+		}))
+	}
+	return opts
 }
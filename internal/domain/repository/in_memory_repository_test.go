@@ -0,0 +1,227 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"awesomeProject3/internal/domain/entity"
+	domainerrors "awesomeProject3/internal/domain/errors"
+	"awesomeProject3/internal/subpub"
+	"github.com/sirupsen/logrus"
+)
+
+func newTestRepository(t *testing.T) *InMemoryRepository {
+	t.Helper()
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	repo := NewInMemoryRepository(subpub.WithLogger(logger))
+	t.Cleanup(func() {
+		repo.Close(context.Background())
+	})
+	return repo
+}
+
+// waitFor polls cond until it holds or a second passes
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSaveDoesNotBlockOnSlowSubscriber(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	release := make(chan struct{})
+	defer close(release)
+	if err := repo.Subscribe(ctx, "orders", SubscribeOptions{}, func(*entity.Event) {
+		<-release
+	}); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	fast := make(chan *entity.Event, 10)
+	if err := repo.Subscribe(ctx, "orders", SubscribeOptions{}, func(e *entity.Event) {
+		fast <- e
+	}); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		if err := repo.Save(ctx, entity.NewEvent("orders", "order")); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("Save took %v with a blocked subscriber", elapsed)
+	}
+
+	for i := 0; i < 10; i++ {
+		select {
+		case <-fast:
+		case <-time.After(time.Second):
+			t.Fatalf("fast subscriber received %d events, want 10", i)
+		}
+	}
+}
+
+func TestCallbackCanSaveAndUnsubscribe(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	done := make(chan struct{})
+	if err := repo.Subscribe(ctx, "orders", SubscribeOptions{}, func(e *entity.Event) {
+		if err := repo.Save(ctx, entity.NewEvent("audit", e.Data)); err != nil {
+			t.Errorf("Save from callback failed: %v", err)
+		}
+		if err := repo.Unsubscribe(ctx, "orders"); err != nil {
+			t.Errorf("Unsubscribe from callback failed: %v", err)
+		}
+	}); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	if err := repo.Subscribe(ctx, "audit", SubscribeOptions{}, func(*entity.Event) {
+		close(done)
+	}); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	if err := repo.Save(ctx, entity.NewEvent("orders", "order")); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("callback deadlocked")
+	}
+
+	if _, err := repo.FindByKey(ctx, "audit"); err != nil {
+		t.Errorf("FindByKey failed: %v", err)
+	}
+}
+
+func TestSubscriptionRemovedWhenContextCancelled(t *testing.T) {
+	repo := newTestRepository(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	received := make(chan *entity.Event, 10)
+	if err := repo.Subscribe(ctx, "orders", SubscribeOptions{}, func(e *entity.Event) {
+		received <- e
+	}); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	cancel()
+	waitFor(t, "subscription removal", func() bool {
+		repo.mu.RLock()
+		defer repo.mu.RUnlock()
+		return len(repo.subscriptions) == 0
+	})
+
+	if err := repo.Save(context.Background(), entity.NewEvent("orders", "order")); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	select {
+	case e := <-received:
+		t.Errorf("cancelled subscription received %q", e.Data)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestSaveInvalidKey(t *testing.T) {
+	repo := newTestRepository(t)
+
+	for _, key := range []string{"", "a..b", "orders.", "orders.*", "orders.>"} {
+		err := repo.Save(context.Background(), entity.NewEvent(key, "data"))
+		if !errors.Is(err, domainerrors.ErrInvalidEventKey) {
+			t.Errorf("Save(%q): got %v, want %v", key, err, domainerrors.ErrInvalidEventKey)
+		}
+		if _, err := repo.FindByKey(context.Background(), key); !errors.Is(err, domainerrors.ErrEventNotFound) {
+			t.Errorf("FindByKey(%q): got %v, want %v", key, err, domainerrors.ErrEventNotFound)
+		}
+	}
+}
+
+func TestSubscribeOverflowPolicy(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	defer close(release)
+	dropped := make(chan string, 10)
+	opts := SubscribeOptions{
+		BufferSize:     1,
+		OverflowPolicy: DropOldest,
+		OnDrop: func(e *entity.Event, policy OverflowPolicy) {
+			if policy != DropOldest {
+				t.Errorf("OnDrop policy = %v, want %v", policy, DropOldest)
+			}
+			dropped <- e.Data
+		},
+	}
+	if err := repo.Subscribe(ctx, "orders", opts, func(*entity.Event) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+	}); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	repo.Save(ctx, entity.NewEvent("orders", "0"))
+	<-started
+	// The handler is busy with event 0 and the queue holds one event, so 2 evicts 1
+	repo.Save(ctx, entity.NewEvent("orders", "1"))
+	repo.Save(ctx, entity.NewEvent("orders", "2"))
+
+	select {
+	case data := <-dropped:
+		if data != "1" {
+			t.Errorf("dropped %q, want %q", data, "1")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("OnDrop was not called")
+	}
+}
+
+func TestCloseRejectsSave(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	if err := repo.Close(ctx); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := repo.Save(ctx, entity.NewEvent("orders", "order")); !errors.Is(err, domainerrors.ErrServiceClosed) {
+		t.Errorf("Save: got %v, want %v", err, domainerrors.ErrServiceClosed)
+	}
+}
+
+func TestSaveRacingCloseDoesNotStoreEvent(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	// The bus is closed but the repository is not yet: the state Save sees
+	// when Close runs between storing and publishing the event
+	if err := repo.bus.Close(ctx); err != nil {
+		t.Fatalf("bus Close failed: %v", err)
+	}
+	if err := repo.Save(ctx, entity.NewEvent("orders", "order")); !errors.Is(err, domainerrors.ErrServiceClosed) {
+		t.Errorf("Save: got %v, want %v", err, domainerrors.ErrServiceClosed)
+	}
+	if _, err := repo.FindByKey(ctx, "orders"); !errors.Is(err, domainerrors.ErrEventNotFound) {
+		t.Errorf("FindByKey: got %v, want %v", err, domainerrors.ErrEventNotFound)
+	}
+}
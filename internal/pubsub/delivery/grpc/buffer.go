@@ -0,0 +1,84 @@
+package grpc
+
+import (
+	"sync"
+	"time"
+
+	"awesomeProject3/internal/subpub"
+	"awesomeProject3/pkg/proto"
+)
+
+// streamBuffer буферизует события одного gRPC потока и применяет
+// политику переполнения, когда клиент не успевает их вычитывать
+type streamBuffer struct {
+	events       chan *proto.Event
+	policy       subpub.OverflowPolicy
+	blockTimeout time.Duration
+	done         <-chan struct{}
+
+	// overflow закрывается, когда политика disconnect требует разорвать поток
+	overflow     chan struct{}
+	overflowOnce sync.Once
+}
+
+func newStreamBuffer(size int, policy subpub.OverflowPolicy, blockTimeout time.Duration, done <-chan struct{}) *streamBuffer {
+	return &streamBuffer{
+		events:       make(chan *proto.Event, size),
+		policy:       policy,
+		blockTimeout: blockTimeout,
+		done:         done,
+		overflow:     make(chan struct{}),
+	}
+}
+
+// push помещает событие в буфер и возвращает число отброшенных событий
+func (b *streamBuffer) push(event *proto.Event) uint64 {
+	select {
+	case <-b.done:
+		return 0
+	case b.events <- event:
+		return 0
+	default:
+	}
+
+	switch b.policy {
+	case subpub.DropOldest:
+		var dropped uint64
+		for {
+			select {
+			case b.events <- event:
+				return dropped
+			default:
+			}
+			select {
+			case <-b.events:
+				dropped++
+			default:
+			}
+		}
+
+	case subpub.Block:
+		timer := time.NewTimer(b.blockTimeout)
+		defer timer.Stop()
+		select {
+		case b.events <- event:
+			return 0
+		case <-b.done:
+			return 0
+		case <-timer.C:
+			return 1
+		}
+
+	case subpub.Disconnect:
+		b.disconnect()
+		return 1
+
+	default:
+		return 1
+	}
+}
+
+// disconnect сообщает обработчику потока, что подписчик не успевает получать события
+func (b *streamBuffer) disconnect() {
+	b.overflowOnce.Do(func() { close(b.overflow) })
+}
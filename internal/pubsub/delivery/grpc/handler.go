@@ -2,15 +2,24 @@ package grpc
 
 import (
 	"context"
+	"errors"
+	"math"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"awesomeProject3/internal/domain/entity"
+	domainerrors "awesomeProject3/internal/domain/errors"
+	"awesomeProject3/internal/domain/repository"
+	"awesomeProject3/internal/subpub"
 	"awesomeProject3/internal/usecase/publish"
 	"awesomeProject3/internal/usecase/subscribe"
+	"awesomeProject3/pkg/config"
 	"awesomeProject3/pkg/proto"
 	"awesomeProject3/pkg/validator"
 	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
 )
@@ -23,33 +32,88 @@ type Handler struct {
 	subscribeUC subscribe.UseCase
 	mu          sync.RWMutex
 	subs        map[string]map[chan *proto.Event]struct{}
+
+	bufferSize   int
+	policy       subpub.OverflowPolicy
+	blockTimeout time.Duration
+	maxInFlight  int
+	dropped      atomic.Uint64
+}
+
+// repositoryPolicies сопоставляет политики буфера потока политикам очереди подписки в репозитории
+var repositoryPolicies = map[subpub.OverflowPolicy]repository.OverflowPolicy{
+	subpub.DropNewest: repository.DropNewest,
+	subpub.DropOldest: repository.DropOldest,
+	subpub.Block:      repository.Block,
+	subpub.Disconnect: repository.Disconnect,
 }
 
+// defaultMaxInFlight — ограничение max_in_flight, если оно не задано в конфигурации
+const defaultMaxInFlight = 64
+
 // NewHandler создает новый обработчик gRPC
 func NewHandler(
 	logger *logrus.Logger,
 	publishUC publish.UseCase,
 	subscribeUC subscribe.UseCase,
+	cfg config.PubSubConfig,
 ) *Handler {
+	policy, err := subpub.ParseOverflowPolicy(cfg.OverflowPolicy)
+	if err != nil {
+		logger.WithError(err).Warn("неизвестная политика переполнения, используется drop_newest")
+	}
+
+	bufferSize := cfg.MessageBufferSize
+	if bufferSize < 1 {
+		bufferSize = 100
+	}
+
+	blockTimeout := cfg.BlockTimeout.Duration
+	if blockTimeout <= 0 {
+		blockTimeout = subpub.DefaultBlockTimeout
+	}
+
+	maxInFlight := cfg.MaxInFlight
+	if maxInFlight < 1 {
+		maxInFlight = defaultMaxInFlight
+	}
+
 	return &Handler{
-		logger:      logger,
-		publishUC:   publishUC,
-		subscribeUC: subscribeUC,
-		subs:        make(map[string]map[chan *proto.Event]struct{}),
+		logger:       logger,
+		publishUC:    publishUC,
+		subscribeUC:  subscribeUC,
+		subs:         make(map[string]map[chan *proto.Event]struct{}),
+		bufferSize:   bufferSize,
+		policy:       policy,
+		blockTimeout: blockTimeout,
+		maxInFlight:  maxInFlight,
 	}
 }
 
+// DroppedEvents возвращает общее число событий, отброшенных из-за переполнения буферов подписчиков
+func (h *Handler) DroppedEvents() uint64 {
+	return h.dropped.Load()
+}
+
 // Subscribe обрабатывает запрос на подписку
 func (h *Handler) Subscribe(req *proto.SubscribeRequest, stream proto.PubSub_SubscribeServer) error {
 	if err := validator.ValidateNotEmpty(req.GetKey(), "key"); err != nil {
 		return status.Error(codes.InvalidArgument, "требуется указать ключ")
 	}
 
+	if rate := req.GetRateLimit(); rate < 0 || math.IsNaN(rate) || math.IsInf(rate, 0) {
+		return status.Error(codes.InvalidArgument, "некорректное ограничение скорости")
+	}
+
+	if req.GetMaxInFlight() > uint32(h.maxInFlight) {
+		return status.Errorf(codes.InvalidArgument, "max_in_flight не может превышать %d", h.maxInFlight)
+	}
+
 	key := req.GetKey()
 
-	// Создаем канал для этой подписки
-	events := make(chan *proto.Event, 100)
-	defer close(events)
+	// Создаем буфер для этой подписки
+	buffer := newStreamBuffer(h.bufferSize, h.policy, h.blockTimeout, stream.Context().Done())
+	events := buffer.events
 
 	// Регистрируем подписку
 	h.mu.Lock()
@@ -70,28 +134,67 @@ func (h *Handler) Subscribe(req *proto.SubscribeRequest, stream proto.PubSub_Sub
 	}()
 
 	// Подписываемся используя use case
-	err := h.subscribeUC.Execute(stream.Context(), subscribe.Request{Key: key}, func(event *entity.Event) {
-		protoEvent := &proto.Event{Data: event.Data}
-		select {
-		case events <- protoEvent:
-		default:
-			h.logger.WithField("key", key).Warn("буфер подписчика переполнен, сообщение отброшено")
+	var streamDropped atomic.Uint64
+	dropped := func(n uint64) {
+		total := h.dropped.Add(n)
+		h.logger.WithFields(logrus.Fields{
+			"key":           key,
+			"policy":        h.policy.String(),
+			"dropped":       streamDropped.Add(n),
+			"dropped_total": total,
+		}).Warn("буфер подписчика переполнен, сообщение отброшено")
+	}
+	subReq := subscribe.Request{
+		Key:         key,
+		Filter:      req.GetFilter(),
+		RateLimit:   req.GetRateLimit(),
+		Burst:       int(req.GetBurst()),
+		// События пишутся в поток по одному: параллельные вызовы
+		// не ускорили бы отправку, но перемешали бы порядок событий
+		MaxInFlight: 1,
+
+		// Очередь подписки на шине подчиняется тем же настройкам, что и буфер потока
+		BufferSize:     h.bufferSize,
+		OverflowPolicy: repositoryPolicies[h.policy],
+		BlockTimeout:   h.blockTimeout,
+		OnDrop: func(*entity.Event, repository.OverflowPolicy) {
+			dropped(1)
+			if h.policy == subpub.Disconnect {
+				buffer.disconnect()
+			}
+		},
+	}
+	err := h.subscribeUC.Execute(stream.Context(), subReq, func(event *entity.Event) {
+		if n := buffer.push(&proto.Event{Data: event.Data, Metadata: event.Metadata, Key: event.Key}); n > 0 {
+			dropped(n)
 		}
 	})
+	if errors.Is(err, domainerrors.ErrInvalidFilter) || errors.Is(err, domainerrors.ErrInvalidEventKey) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
 	if err != nil {
 		return status.Error(codes.Internal, "не удалось подписаться")
 	}
 
+	// Пустой заголовок сообщает клиенту, что подписка зарегистрирована
+	// и события, опубликованные после этого, будут ему доставлены
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return status.Error(codes.Internal, "не удалось отправить заголовок")
+	}
+
 	// Отправляем события клиенту
 	for {
 		select {
-		case event, ok := <-events:
-			if !ok {
-				return nil
-			}
+		case event := <-events:
 			if err := stream.Send(event); err != nil {
 				return status.Error(codes.Internal, "не удалось отправить событие")
 			}
+		case <-buffer.overflow:
+			h.logger.WithFields(logrus.Fields{
+				"key":     key,
+				"dropped": streamDropped.Load(),
+			}).Warn("подписчик не успевает получать события, поток разорван")
+			return status.Error(codes.ResourceExhausted, "подписчик не успевает получать события")
 		case <-stream.Context().Done():
 			return nil
 		}
@@ -109,9 +212,13 @@ func (h *Handler) Publish(ctx context.Context, req *proto.PublishRequest) (*empt
 
 	// Публикуем используя use case
 	err := h.publishUC.Execute(ctx, publish.Request{
-		Key:  req.GetKey(),
-		Data: req.GetData(),
+		Key:      req.GetKey(),
+		Data:     req.GetData(),
+		Metadata: req.GetMetadata(),
 	})
+	if errors.Is(err, domainerrors.ErrInvalidEventKey) || errors.Is(err, domainerrors.ErrInvalidEventData) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
 	if err != nil {
 		return nil, status.Error(codes.Internal, "не удалось опубликовать")
 	}
@@ -0,0 +1,282 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"awesomeProject3/internal/domain/entity"
+	"awesomeProject3/internal/domain/repository"
+	"awesomeProject3/internal/subpub"
+	"awesomeProject3/internal/usecase/publish"
+	"awesomeProject3/internal/usecase/subscribe"
+	"awesomeProject3/pkg/config"
+	"awesomeProject3/pkg/proto"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newTestClient запускает обработчик на bufconn и возвращает клиента к нему.
+func newTestClient(t *testing.T, cfg config.PubSubConfig) (proto.PubSubClient, *Handler) {
+	t.Helper()
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	repo := repository.NewInMemoryRepository(subpub.WithLogger(logger))
+	t.Cleanup(func() {
+		repo.Close(context.Background())
+	})
+	handler := NewHandler(logger, publish.New(repo, logger), subscribe.New(repo, logger), cfg)
+	return serve(t, handler), handler
+}
+
+// serve запускает сервер с обработчиком handler на bufconn.
+func serve(t *testing.T, handler *Handler) proto.PubSubClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	proto.RegisterPubSubServer(server, handler)
+	go server.Serve(lis)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("failed to dial test server: %v", err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		server.Stop()
+	})
+	return proto.NewPubSubClient(conn)
+}
+
+// subscribeStream открывает поток подписки и дожидается её регистрации.
+func subscribeStream(t *testing.T, ctx context.Context, client proto.PubSubClient, req *proto.SubscribeRequest) proto.PubSub_SubscribeClient {
+	t.Helper()
+
+	stream, err := client.Subscribe(ctx, req)
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	if _, err := stream.Header(); err != nil {
+		t.Fatalf("Header failed: %v", err)
+	}
+	return stream
+}
+
+func TestSubscribeInvalidFilter(t *testing.T) {
+	client, _ := newTestClient(t, config.Default().PubSub)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	for _, expr := range []string{`data ==`, `size == "1"`, `key matches "("`} {
+		stream, err := client.Subscribe(ctx, &proto.SubscribeRequest{Key: "orders", Filter: expr})
+		if err != nil {
+			t.Fatalf("Subscribe failed: %v", err)
+		}
+		_, err = stream.Recv()
+		if code := status.Code(err); code != codes.InvalidArgument {
+			t.Errorf("filter %q: got %v (%v), want %v", expr, code, err, codes.InvalidArgument)
+		}
+	}
+}
+
+func TestInvalidKeys(t *testing.T) {
+	client, _ := newTestClient(t, config.Default().PubSub)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	for _, key := range []string{"a..b", "orders.", "orders.*", "orders.>"} {
+		_, err := client.Publish(ctx, &proto.PublishRequest{Key: key, Data: "data"})
+		if code := status.Code(err); code != codes.InvalidArgument {
+			t.Errorf("Publish(%q): got %v (%v), want %v", key, code, err, codes.InvalidArgument)
+		}
+	}
+	for _, key := range []string{"a..b", "orders.", "orders.>.x"} {
+		stream, err := client.Subscribe(ctx, &proto.SubscribeRequest{Key: key})
+		if err != nil {
+			t.Fatalf("Subscribe failed: %v", err)
+		}
+		if _, err := stream.Recv(); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("Subscribe(%q): got %v, want %v", key, err, codes.InvalidArgument)
+		}
+	}
+}
+
+func TestSubscribeFilteredEventsNeverReachStream(t *testing.T) {
+	client, _ := newTestClient(t, config.Default().PubSub)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	stream := subscribeStream(t, ctx, client, &proto.SubscribeRequest{
+		Key:    "orders",
+		Filter: `metadata.region == "eu" && !(data contains "test")`,
+	})
+
+	published := []*proto.PublishRequest{
+		{Key: "orders", Data: "us order", Metadata: map[string]string{"region": "us"}},
+		{Key: "orders", Data: "test order", Metadata: map[string]string{"region": "eu"}},
+		{Key: "orders", Data: "no metadata"},
+		{Key: "orders", Data: "eu order", Metadata: map[string]string{"region": "eu"}},
+	}
+	for _, req := range published {
+		if _, err := client.Publish(ctx, req); err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+	}
+
+	// События одного ключа доставляются по порядку: если бы отфильтрованные
+	// события попадали в поток, они пришли бы раньше последнего
+	event, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+	if event.GetData() != "eu order" {
+		t.Errorf("got %q, want %q", event.GetData(), "eu order")
+	}
+}
+
+func TestSubscribeMaxInFlightLimit(t *testing.T) {
+	cfg := config.Default().PubSub
+	cfg.MaxInFlight = 8
+	client, _ := newTestClient(t, cfg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	stream, err := client.Subscribe(ctx, &proto.SubscribeRequest{Key: "orders", MaxInFlight: 4_000_000_000})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("got %v, want %v", err, codes.InvalidArgument)
+	}
+
+	subscribeStream(t, ctx, client, &proto.SubscribeRequest{Key: "orders", MaxInFlight: 8})
+}
+
+func TestSubscribeRateLimitedOverflowIsCounted(t *testing.T) {
+	cfg := config.Default().PubSub
+	cfg.MessageBufferSize = 1
+	cfg.OverflowPolicy = "drop_newest"
+	client, handler := newTestClient(t, cfg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	stream := subscribeStream(t, ctx, client, &proto.SubscribeRequest{Key: "orders", RateLimit: 0.1, Burst: 1})
+
+	for i := 0; i < 10; i++ {
+		if _, err := client.Publish(ctx, &proto.PublishRequest{Key: "orders", Data: "event"}); err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+
+	// Первое событие доставлено сразу, в очереди подписки ждёт не больше
+	// одного события, остальные отброшены по политике drop_newest
+	if got := handler.DroppedEvents(); got < 8 {
+		t.Errorf("DroppedEvents = %d, want at least 8", got)
+	}
+}
+
+func TestSubscribeStreamKeepsPublishOrder(t *testing.T) {
+	const (
+		publisherCount = 8
+		eventCount     = 500
+	)
+	cfg := config.Default().PubSub
+	cfg.MessageBufferSize = publisherCount * eventCount
+	cfg.OverflowPolicy = "block"
+	cfg.MaxInFlight = 8
+	client, _ := newTestClient(t, cfg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	stream := subscribeStream(t, ctx, client, &proto.SubscribeRequest{Key: "orders", MaxInFlight: 8})
+
+	// Издатели работают параллельно, чтобы события копились в очереди подписки.
+	// События каждого издателя должны прийти в порядке их публикации
+	var wg sync.WaitGroup
+	for p := 0; p < publisherCount; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < eventCount; i++ {
+				req := &proto.PublishRequest{Key: "orders", Data: fmt.Sprintf("%d-%d", p, i)}
+				if _, err := client.Publish(ctx, req); err != nil {
+					t.Errorf("Publish failed: %v", err)
+					return
+				}
+			}
+		}(p)
+	}
+
+	next := make([]int, publisherCount)
+	for n := 0; n < publisherCount*eventCount; n++ {
+		event, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv failed: %v", err)
+		}
+		var p, i int
+		if _, err := fmt.Sscanf(event.GetData(), "%d-%d", &p, &i); err != nil {
+			t.Fatalf("unexpected event %q", event.GetData())
+		}
+		if i != next[p] {
+			t.Fatalf("publisher %d: got event %d, want %d", p, i, next[p])
+		}
+		next[p]++
+	}
+	wg.Wait()
+}
+
+// recordingSubscribe запоминает запрос подписки и отклоняет его.
+type recordingSubscribe struct {
+	requests chan subscribe.Request
+}
+
+func (r *recordingSubscribe) Execute(_ context.Context, req subscribe.Request, _ func(*entity.Event)) error {
+	r.requests <- req
+	return errors.New("rejected")
+}
+
+func TestSubscribeStreamDeliversSerially(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	uc := &recordingSubscribe{requests: make(chan subscribe.Request, 1)}
+	client := serve(t, NewHandler(logger, nil, uc, config.Default().PubSub))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	stream, err := client.Subscribe(ctx, &proto.SubscribeRequest{Key: "orders", MaxInFlight: 8})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	stream.Recv()
+
+	if req := <-uc.requests; req.MaxInFlight != 1 {
+		t.Errorf("MaxInFlight = %d, want 1: stream events must be written one at a time", req.MaxInFlight)
+	}
+}
@@ -0,0 +1,161 @@
+package subpub
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxDeliveries — число попыток доставки сообщения в режиме подтверждений по умолчанию.
+	DefaultMaxDeliveries = 5
+	// DefaultRedeliveryBackoff — задержка перед первой повторной доставкой по умолчанию.
+	DefaultRedeliveryBackoff = 100 * time.Millisecond
+	// DefaultMaxRedeliveryBackoff — предельная задержка между повторными доставками по умолчанию.
+	DefaultMaxRedeliveryBackoff = 10 * time.Second
+)
+
+// AckHandler обрабатывает сообщение в режиме подтверждений (at-least-once).
+// Возврат nil подтверждает сообщение, ошибка отклоняет его. Обработчик
+// может и явно вызвать Delivery.Ack или Delivery.Nack: тогда учитывается
+// первый из этих вызовов, а возвращаемое значение игнорируется.
+type AckHandler func(ctx context.Context, d *Delivery) error
+
+// Delivery — сообщение, доставленное обработчику в режиме подтверждений.
+// Ack и Nack действуют только до возврата из обработчика.
+type Delivery struct {
+	// Subject — субъект, в который было опубликовано сообщение
+	Subject string
+	// Msg — само сообщение
+	Msg interface{}
+	// Attempt — номер попытки доставки, начиная с 1
+	Attempt int
+
+	mu      sync.Mutex
+	settled bool
+	reason  error
+}
+
+// Ack подтверждает обработку сообщения.
+func (d *Delivery) Ack() {
+	d.settle(nil)
+}
+
+// Nack отклоняет сообщение: оно будет доставлено повторно или, когда
+// попытки закончатся, отправлено в субъект недоставленных сообщений
+// с указанной причиной. Пустая причина заменяется на ErrNacked.
+func (d *Delivery) Nack(reason error) {
+	if reason == nil {
+		reason = ErrNacked
+	}
+	d.settle(reason)
+}
+
+func (d *Delivery) settle(reason error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.settled {
+		d.settled, d.reason = true, reason
+	}
+}
+
+// close завершает доставку. Явный Ack или Nack имеет приоритет над
+// результатом обработчика; после close они ни на что не влияют.
+func (d *Delivery) close(err error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.settled {
+		d.settled, d.reason = true, err
+	}
+	return d.reason
+}
+
+func (cb AckHandler) deliver(ctx context.Context, env *envelope) error {
+	d := &Delivery{
+		Subject: env.subject,
+		Msg:     env.msg,
+		Attempt: env.attempt,
+	}
+	return d.close(cb(ctx, d))
+}
+
+// DeadLetter описывает сообщение, которое не удалось обработать за отведённое
+// число попыток. Он публикуется в субъект недоставленных сообщений
+// (WithDeadLetter) и передаётся обработчику неудач (WithFailureHandler);
+// если не задано ни то ни другое, DeadLetter передаётся в ErrorHook шины как ошибка.
+type DeadLetter struct {
+	// Subject — субъект, в который было опубликовано сообщение
+	Subject string
+	// Pattern — субъект или шаблон подписки
+	Pattern string
+	// Group — группа очередей подписки, пустая для обычной подписки
+	Group string
+	// Msg — необработанное сообщение
+	Msg interface{}
+	// Attempts — число сделанных попыток доставки
+	Attempts int
+	// Reason — причина отказа при последней попытке
+	Reason error
+}
+
+func (d *DeadLetter) Error() string {
+	return fmt.Sprintf("subpub: message on %q failed after %d attempts: %v", d.Subject, d.Attempts, d.Reason)
+}
+
+func (d *DeadLetter) Unwrap() error {
+	return d.Reason
+}
+
+// SubscribeAck подписывает обработчик в режиме подтверждений: сообщение,
+// которое обработчик не подтвердил, доставляется повторно согласно политике
+// повторов подписки (по умолчанию — с экспоненциально растущей задержкой).
+// После исчерпания попыток сообщение отправляется в субъект недоставленных
+// сообщений (WithDeadLetter).
+// Пока сообщение ожидает повторной доставки, следующие сообщения подписки
+// не доставляются, поэтому порядок доставки сохраняется.
+func (sp *subPub) SubscribeAck(subject string, cb AckHandler, opts ...SubscribeOption) (Subscription, error) {
+	return subscribed(sp.subscribe(subject, cb.deliver, append(opts[:len(opts):len(opts)], withRetries())))
+}
+
+// WithMaxDeliveries задаёт число попыток доставки сообщения в режиме
+// подтверждений или с повтором обработки.
+// Неположительные значения игнорируются.
+func WithMaxDeliveries(n int) SubscribeOption {
+	return func(o *subscribeOptions) {
+		if n > 0 {
+			o.retryPolicy.MaxAttempts = n
+		}
+		o.retryOptions = true
+	}
+}
+
+// WithRedeliveryBackoff задаёт задержку перед первой повторной доставкой
+// и её предел: каждая следующая задержка вдвое больше предыдущей.
+// Неположительные значения заменяются значениями по умолчанию.
+func WithRedeliveryBackoff(initial, max time.Duration) SubscribeOption {
+	if initial <= 0 {
+		initial = DefaultRedeliveryBackoff
+	}
+	if max <= 0 {
+		max = DefaultMaxRedeliveryBackoff
+	}
+	return func(o *subscribeOptions) {
+		o.retryPolicy.Backoff = ExponentialBackoff(initial, max, 0)
+		o.retryOptions = true
+	}
+}
+
+// WithDeadLetter задаёт субъект, в который публикуется DeadLetter для
+// сообщений, не подтверждённых за отведённое число попыток или ожидавших
+// повторной доставки, когда подписку остановили. Субъект не должен
+// подходить под шаблон самой подписки, иначе подписка не создаётся
+// и возвращается ErrDeadLetterLoop.
+func WithDeadLetter(subject string) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.deadLetter = subject
+		o.retryOptions = true
+	}
+}
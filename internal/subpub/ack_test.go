@@ -0,0 +1,223 @@
+package subpub
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errHandler = errors.New("handler failed")
+
+func TestAckRedeliversUntilSuccess(t *testing.T) {
+	sp := NewSubPub()
+
+	var mu sync.Mutex
+	var attempts []int
+	var times []time.Time
+	done := make(chan struct{})
+	sub, err := sp.SubscribeAck("test", func(_ context.Context, d *Delivery) error {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts = append(attempts, d.Attempt)
+		times = append(times, time.Now())
+		if d.Attempt < 3 {
+			return errHandler
+		}
+		close(done)
+		return nil
+	}, WithRedeliveryBackoff(20*time.Millisecond, time.Second))
+	if err != nil {
+		t.Fatalf("SubscribeAck failed: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	sp.Publish("test", "msg")
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("message was not redelivered")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(attempts) != 3 || attempts[0] != 1 || attempts[2] != 3 {
+		t.Fatalf("attempts = %v, want [1 2 3]", attempts)
+	}
+	// Задержка растёт экспоненциально: 20ms, затем 40ms
+	if d := times[2].Sub(times[1]); d < 40*time.Millisecond {
+		t.Errorf("second backoff = %v, want at least 40ms", d)
+	}
+	if stats := sub.Stats(); stats.Redelivered != 2 || stats.DeadLettered != 0 {
+		t.Errorf("stats = %+v, want 2 redelivered, 0 dead-lettered", stats)
+	}
+}
+
+func TestAckDeadLetter(t *testing.T) {
+	sp := NewSubPub()
+
+	letters := make(chan *DeadLetter, 1)
+	dlq, err := sp.Subscribe("dead.orders", func(msg interface{}) {
+		letters <- msg.(*DeadLetter)
+	})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer dlq.Unsubscribe()
+
+	sub, err := sp.SubscribeAck("orders.*", func(_ context.Context, d *Delivery) error {
+		d.Nack(errHandler)
+		return nil
+	}, WithMaxDeliveries(3), WithRedeliveryBackoff(time.Millisecond, time.Millisecond), WithDeadLetter("dead.orders"))
+	if err != nil {
+		t.Fatalf("SubscribeAck failed: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	sp.Publish("orders.created", "order")
+
+	select {
+	case letter := <-letters:
+		if letter.Subject != "orders.created" || letter.Pattern != "orders.*" || letter.Msg != "order" ||
+			letter.Attempts != 3 || !errors.Is(letter.Reason, errHandler) {
+			t.Errorf("dead letter = %+v", letter)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("message was not dead-lettered")
+	}
+
+	if stats := sub.Stats(); stats.Delivered != 3 || stats.DeadLettered != 1 {
+		t.Errorf("stats = %+v, want 3 delivered, 1 dead-lettered", stats)
+	}
+}
+
+func TestAckExplicitAckOverridesError(t *testing.T) {
+	sp := NewSubPub()
+
+	calls := make(chan int, 10)
+	sub, err := sp.SubscribeAck("test", func(_ context.Context, d *Delivery) error {
+		calls <- d.Attempt
+		d.Ack()
+		return errHandler
+	}, WithRedeliveryBackoff(time.Millisecond, time.Millisecond))
+	if err != nil {
+		t.Fatalf("SubscribeAck failed: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	sp.Publish("test", "msg")
+	<-calls
+
+	select {
+	case attempt := <-calls:
+		t.Errorf("acknowledged message was redelivered, attempt %d", attempt)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestAckPanicIsRedelivered(t *testing.T) {
+	errs := make(chan error, 10)
+	sp := NewSubPub(WithErrorHook(func(err error) { errs <- err }))
+
+	sub, err := sp.SubscribeAck("test", func(_ context.Context, d *Delivery) error {
+		panic("boom")
+	}, WithMaxDeliveries(2), WithRedeliveryBackoff(time.Millisecond, time.Millisecond))
+	if err != nil {
+		t.Fatalf("SubscribeAck failed: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	sp.Publish("test", "msg")
+
+	// Две паники и итоговый DeadLetter, так как субъект недоставленных сообщений не задан
+	var letter *DeadLetter
+	for i := 0; i < 3; i++ {
+		select {
+		case err := <-errs:
+			errors.As(err, &letter)
+		case <-time.After(time.Second):
+			t.Fatalf("received %d errors, want 3", i)
+		}
+	}
+	if letter == nil || letter.Attempts != 2 {
+		t.Fatalf("dead letter = %+v, want 2 attempts", letter)
+	}
+	var panicErr *PanicError
+	if !errors.As(letter.Reason, &panicErr) {
+		t.Errorf("Reason = %v, want *PanicError", letter.Reason)
+	}
+}
+
+func TestAckRedeliveryStopsOnUnsubscribe(t *testing.T) {
+	sp := NewSubPub()
+
+	attempts := make(chan int, 10)
+	letters := make(chan *DeadLetter, 1)
+	sub, err := sp.SubscribeAck("test", func(_ context.Context, d *Delivery) error {
+		attempts <- d.Attempt
+		return errHandler
+	}, WithRedeliveryBackoff(time.Hour, time.Hour), WithFailureHandler(func(letter *DeadLetter) {
+		letters <- letter
+	}))
+	if err != nil {
+		t.Fatalf("SubscribeAck failed: %v", err)
+	}
+
+	sp.Publish("test", "msg")
+	<-attempts
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := sub.UnsubscribeWait(ctx); err != nil {
+		t.Fatalf("UnsubscribeWait: %v", err)
+	}
+
+	// Сообщение, ожидавшее повторной доставки, не теряется молча
+	select {
+	case letter := <-letters:
+		if letter.Msg != "msg" || letter.Attempts != 1 {
+			t.Errorf("letter = %+v, want msg after 1 attempt", letter)
+		}
+		if !errors.Is(letter, ErrRedeliveryStopped) || !errors.Is(letter, errHandler) {
+			t.Errorf("Reason = %v, want %v wrapping %v", letter.Reason, ErrRedeliveryStopped, errHandler)
+		}
+	default:
+		t.Fatal("failure handler was not called")
+	}
+	if stats := sub.Stats(); stats.DeadLettered != 1 {
+		t.Errorf("DeadLettered = %d, want 1", stats.DeadLettered)
+	}
+}
+
+func TestInvalidDeadLetterSubject(t *testing.T) {
+	sp := NewSubPub()
+
+	_, err := sp.SubscribeAck("test", func(context.Context, *Delivery) error { return nil }, WithDeadLetter("dead.*"))
+	if err != ErrWildcardSubject {
+		t.Errorf("SubscribeAck: got %v, want %v", err, ErrWildcardSubject)
+	}
+}
+
+func TestDeadLetterSubjectMatchingPattern(t *testing.T) {
+	sp := NewSubPub()
+	handler := func(context.Context, *Delivery) error { return nil }
+
+	for _, tt := range []struct{ subject, deadLetter string }{
+		{"orders", "orders"},
+		{"orders.>", "orders.dead"},
+		{"orders.*", "orders.dead"},
+		{"*.dead", "orders.dead"},
+	} {
+		if _, err := sp.SubscribeAck(tt.subject, handler, WithDeadLetter(tt.deadLetter)); err != ErrDeadLetterLoop {
+			t.Errorf("SubscribeAck(%q, %q): got %v, want %v", tt.subject, tt.deadLetter, err, ErrDeadLetterLoop)
+		}
+	}
+
+	sub, err := sp.SubscribeAck("orders.*", handler, WithDeadLetter("orders.dead.letters"))
+	if err != nil {
+		t.Fatalf("SubscribeAck failed: %v", err)
+	}
+	sub.Unsubscribe()
+}
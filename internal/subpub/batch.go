@@ -0,0 +1,158 @@
+package subpub
+
+import (
+	"runtime/debug"
+	"time"
+)
+
+// BatchHandler обрабатывает пакет сообщений в порядке их публикации.
+type BatchHandler func(msgs []interface{})
+
+type batchOptions struct {
+	maxSize int
+	maxWait time.Duration
+	handler BatchHandler
+}
+
+// SubscribeBatch подписывает обработчик, получающий сообщения пакетами.
+// Пакет передаётся обработчику, как только в нём набирается maxSize
+// сообщений или самое старое сообщение пакета ждёт дольше maxWait
+// (неположительный maxWait отключает отправку по времени). При отписке
+// и закрытии шины обработчик получает оставшиеся сообщения.
+// Порядок сообщений сохраняется как внутри пакета, так и между пакетами.
+// Перехватчики доставки вызываются для каждого сообщения при добавлении его в пакет.
+//
+// WithRateLimit ограничивает скорость передачи пакетов, а не отдельных
+// сообщений; пакеты, оставшиеся при отписке, передаются без ожидания.
+// Пакеты всегда обрабатываются по одному, а повторной обработки у пакетной
+// подписки нет, поэтому с WithMaxInFlight больше 1 и параметрами повторов
+// (WithRetryPolicy, WithMaxDeliveries, WithRedeliveryBackoff,
+// WithFailureHandler, WithDeadLetter) SubscribeBatch возвращает ErrBatchOption.
+func (sp *subPub) SubscribeBatch(subject string, maxSize int, maxWait time.Duration, cb BatchHandler, opts ...SubscribeOption) (Subscription, error) {
+	if maxSize < 1 {
+		return nil, ErrInvalidBatchSize
+	}
+	batch := &batchOptions{
+		maxSize: maxSize,
+		maxWait: maxWait,
+		handler: cb,
+	}
+	return subscribed(sp.subscribe(subject, nil, append(opts[:len(opts):len(opts)], func(o *subscribeOptions) {
+		o.batch = batch
+	})))
+}
+
+// runBatches — цикл доставки пакетной подписки.
+func (s *subscription) runBatches() {
+	b := s.opts.batch
+	batch := make([]interface{}, 0, b.maxSize)
+	// envs — конверты сообщений пакета, нужны только наблюдателям
+	var envs []*envelope
+	push := func(env *envelope) {
+		n := len(batch)
+		batch = s.add(batch, env)
+		if s.observer() != nil && len(batch) > n {
+			envs = append(envs, env)
+		}
+	}
+
+	var timer *time.Timer
+	var timeout <-chan time.Time
+	flush := func() {
+		if timer != nil {
+			timer.Stop()
+			timer, timeout = nil, nil
+		}
+		if len(batch) > 0 {
+			// После остановки подписки acquire не ждёт и пакет передаётся сразу
+			s.acquire()
+			s.flush(batch, envs)
+			batch = make([]interface{}, 0, b.maxSize)
+			envs = envs[:0]
+		}
+	}
+
+	for {
+		s.waitResumed()
+
+		select {
+		case <-s.done:
+			// Очередь больше не пополняется: оставшиеся сообщения
+			// доставляются пакетами не больше maxSize
+			for {
+				env, ok := s.dequeue()
+				if !ok {
+					break
+				}
+				push(env)
+				if len(batch) == b.maxSize {
+					flush()
+				}
+			}
+			flush()
+			return
+		case <-timeout:
+			flush()
+			continue
+		case <-s.ready:
+		case <-s.draining:
+		}
+
+		draining := isClosed(s.draining)
+
+		for !isClosed(s.done) && !s.paused.Load() {
+			env, ok := s.dequeue()
+			if !ok {
+				break
+			}
+			push(env)
+			if len(batch) == b.maxSize {
+				flush()
+			} else if timer == nil && len(batch) > 0 && b.maxWait > 0 {
+				timer = time.NewTimer(b.maxWait)
+				timeout = timer.C
+			}
+		}
+
+		if draining && !isClosed(s.done) && s.pending() == 0 {
+			flush()
+			return
+		}
+	}
+}
+
+// add пропускает сообщение через перехватчики доставки и добавляет его в пакет.
+func (s *subscription) add(batch []interface{}, env *envelope) []interface{} {
+	interceptors := s.bus.opts.deliveryInterceptors
+	if len(interceptors) == 0 {
+		return append(batch, env.msg)
+	}
+
+	err := chainDelivery(interceptors, s.info(env), func(msg interface{}) error {
+		batch = append(batch, msg)
+		return nil
+	})(env.msg)
+	if err != nil {
+		s.bus.reportError(err)
+	}
+	return batch
+}
+
+// flush передаёт пакет обработчику, не позволяя его панике выйти за пределы подписки.
+func (s *subscription) flush(batch []interface{}, envs []*envelope) {
+	s.delivered.Add(uint64(len(batch)))
+	s.lastDelivery.Store(time.Now().UnixNano())
+
+	defer func() {
+		var err error
+		if r := recover(); r != nil {
+			err = s.recovered(&envelope{subject: s.subject, msg: batch}, r, debug.Stack())
+		}
+		for _, env := range envs {
+			s.observeDeliver(env, err)
+		}
+	}()
+
+	s.opts.batch.handler(batch)
+	s.consecutivePanics.Store(0)
+}
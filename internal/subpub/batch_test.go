@@ -0,0 +1,224 @@
+package subpub
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// batchCollector складывает полученные пакеты в срез.
+type batchCollector struct {
+	mu      sync.Mutex
+	batches [][]interface{}
+}
+
+func subscribeBatches(t *testing.T, sp SubPub, subject string, maxSize int, maxWait time.Duration, opts ...SubscribeOption) (*batchCollector, Subscription) {
+	t.Helper()
+
+	c := &batchCollector{}
+	sub, err := sp.SubscribeBatch(subject, maxSize, maxWait, func(msgs []interface{}) {
+		c.mu.Lock()
+		c.batches = append(c.batches, msgs)
+		c.mu.Unlock()
+	}, opts...)
+	if err != nil {
+		t.Fatalf("SubscribeBatch failed: %v", err)
+	}
+	return c, sub
+}
+
+func (c *batchCollector) get() [][]interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([][]interface{}(nil), c.batches...)
+}
+
+func (c *batchCollector) waitFor(t *testing.T, n int) [][]interface{} {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		got := c.get()
+		if len(got) >= n {
+			return got
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("received %d batches, want %d: %v", len(got), n, got)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestBatchFlushesWhenFull(t *testing.T) {
+	sp := NewSubPub()
+
+	c, sub := subscribeBatches(t, sp, "test", 3, time.Hour)
+	defer sub.Unsubscribe()
+
+	for i := 0; i < 7; i++ {
+		sp.Publish("test", i)
+	}
+
+	got := c.waitFor(t, 2)
+	time.Sleep(50 * time.Millisecond)
+	if got = c.get(); len(got) != 2 || len(got[0]) != 3 || len(got[1]) != 3 {
+		t.Errorf("batches = %v, want two full batches of 3", got)
+	}
+}
+
+func TestBatchFlushesAfterMaxWait(t *testing.T) {
+	sp := NewSubPub()
+
+	c, sub := subscribeBatches(t, sp, "test", 100, 30*time.Millisecond)
+	defer sub.Unsubscribe()
+
+	start := time.Now()
+	sp.Publish("test", 1)
+	sp.Publish("test", 2)
+
+	got := c.waitFor(t, 1)
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("batch flushed after %v, want at least 30ms", elapsed)
+	}
+	if len(got[0]) != 2 || got[0][0] != 1 || got[0][1] != 2 {
+		t.Errorf("batch = %v, want [1 2]", got[0])
+	}
+}
+
+func TestBatchFlushesOnUnsubscribe(t *testing.T) {
+	sp := NewSubPub()
+
+	c, sub := subscribeBatches(t, sp, "test", 100, time.Hour)
+
+	sp.Publish("test", 1)
+	sp.Publish("test", 2)
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := sub.UnsubscribeWait(ctx); err != nil {
+		t.Fatalf("UnsubscribeWait: %v", err)
+	}
+
+	if got := c.get(); len(got) != 1 || len(got[0]) != 2 {
+		t.Errorf("batches = %v, want one batch of 2", got)
+	}
+}
+
+func TestBatchFlushesOnClose(t *testing.T) {
+	sp := NewSubPub()
+
+	c, _ := subscribeBatches(t, sp, "test", 100, time.Hour)
+
+	for i := 0; i < 5; i++ {
+		sp.Publish("test", i)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := sp.Close(ctx); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if got := c.get(); len(got) != 1 || len(got[0]) != 5 {
+		t.Errorf("batches = %v, want one batch of 5", got)
+	}
+}
+
+func TestBatchPreservesOrder(t *testing.T) {
+	sp := NewSubPub()
+
+	const total = 1000
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var got []interface{}
+	sub, err := sp.SubscribeBatch("test", 7, time.Millisecond, func(msgs []interface{}) {
+		<-release
+		if len(msgs) > 7 {
+			t.Errorf("batch of %d messages exceeds maxSize 7", len(msgs))
+		}
+		mu.Lock()
+		got = append(got, msgs...)
+		mu.Unlock()
+	}, WithBufferSize(total))
+	if err != nil {
+		t.Fatalf("SubscribeBatch failed: %v", err)
+	}
+
+	for i := 0; i < total; i++ {
+		sp.Publish("test", i)
+	}
+	close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := sub.UnsubscribeWait(ctx); err != nil {
+		t.Fatalf("UnsubscribeWait: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != total {
+		t.Fatalf("received %d messages, want %d", len(got), total)
+	}
+	for i, msg := range got {
+		if msg != i {
+			t.Fatalf("message %d = %v, want %d", i, msg, i)
+		}
+	}
+}
+
+func TestBatchInvalidSize(t *testing.T) {
+	sp := NewSubPub()
+
+	if _, err := sp.SubscribeBatch("test", 0, time.Second, func([]interface{}) {}); err != ErrInvalidBatchSize {
+		t.Errorf("SubscribeBatch: got %v, want %v", err, ErrInvalidBatchSize)
+	}
+}
+
+func TestBatchRateLimit(t *testing.T) {
+	sp := NewSubPub()
+
+	c, sub := subscribeBatches(t, sp, "test", 2, time.Hour, WithRateLimit(1, 1))
+	for i := 0; i < 6; i++ {
+		sp.Publish("test", i)
+	}
+
+	// Первый пакет передаётся сразу, следующий — не раньше чем через секунду
+	c.waitFor(t, 1)
+	time.Sleep(100 * time.Millisecond)
+	if got := c.get(); len(got) != 1 {
+		t.Fatalf("received %d batches before the rate limit allows, want 1: %v", len(got), got)
+	}
+
+	// При отписке оставшиеся пакеты передаются без ожидания
+	sub.Unsubscribe()
+	if got := c.waitFor(t, 3); len(got) != 3 {
+		t.Errorf("received %d batches, want 3: %v", len(got), got)
+	}
+}
+
+func TestBatchUnsupportedOptions(t *testing.T) {
+	sp := NewSubPub()
+
+	options := map[string]SubscribeOption{
+		"WithMaxInFlight":       WithMaxInFlight(4),
+		"WithRetryPolicy":       WithRetryPolicy(RetryPolicy{MaxAttempts: 3}),
+		"WithMaxDeliveries":     WithMaxDeliveries(3),
+		"WithRedeliveryBackoff": WithRedeliveryBackoff(time.Millisecond, time.Second),
+		"WithFailureHandler":    WithFailureHandler(func(*DeadLetter) {}),
+		"WithDeadLetter":        WithDeadLetter("dead"),
+	}
+	for name, opt := range options {
+		if _, err := sp.SubscribeBatch("test", 10, time.Second, func([]interface{}) {}, opt); err != ErrBatchOption {
+			t.Errorf("SubscribeBatch with %s: got %v, want %v", name, err, ErrBatchOption)
+		}
+	}
+
+	sub, err := sp.SubscribeBatch("test", 10, time.Second, func([]interface{}) {}, WithMaxInFlight(1), WithRateLimit(10, 1))
+	if err != nil {
+		t.Fatalf("SubscribeBatch failed: %v", err)
+	}
+	sub.Unsubscribe()
+}
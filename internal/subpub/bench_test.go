@@ -0,0 +1,112 @@
+package subpub
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"testing"
+)
+
+func benchmarkBus(b *testing.B, sp SubPub) {
+	b.Cleanup(func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		sp.Close(ctx)
+	})
+}
+
+func BenchmarkPublish(b *testing.B) {
+	for _, subscribers := range []int{1, 100, 10000} {
+		b.Run(fmt.Sprintf("subscribers=%d", subscribers), func(b *testing.B) {
+			sp := NewSubPub()
+			benchmarkBus(b, sp)
+
+			for i := 0; i < subscribers; i++ {
+				if _, err := sp.Subscribe("bench", func(msg interface{}) {}); err != nil {
+					b.Fatalf("Subscribe failed: %v", err)
+				}
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				sp.Publish("bench", i)
+			}
+		})
+	}
+}
+
+func BenchmarkPublishWildcard(b *testing.B) {
+	sp := NewSubPub()
+	benchmarkBus(b, sp)
+
+	for i := 0; i < 100; i++ {
+		if _, err := sp.Subscribe(fmt.Sprintf("orders.%d.*", i), func(msg interface{}) {}); err != nil {
+			b.Fatalf("Subscribe failed: %v", err)
+		}
+	}
+	if _, err := sp.Subscribe("orders.>", func(msg interface{}) {}); err != nil {
+		b.Fatalf("Subscribe failed: %v", err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sp.Publish("orders.42.created", i)
+	}
+}
+
+// BenchmarkPublishParallel публикует из нескольких горутин в разные субъекты,
+// у каждого из которых свой подписчик.
+func BenchmarkPublishParallel(b *testing.B) {
+	for _, subjects := range []int{1, 64} {
+		b.Run(fmt.Sprintf("subjects=%d", subjects), func(b *testing.B) {
+			sp := NewSubPub()
+			benchmarkBus(b, sp)
+
+			names := make([]string, subjects)
+			for i := range names {
+				names[i] = fmt.Sprintf("bench.%d", i)
+				if _, err := sp.Subscribe(names[i], func(msg interface{}) {}); err != nil {
+					b.Fatalf("Subscribe failed: %v", err)
+				}
+			}
+
+			var next atomic.Uint64
+			b.SetParallelism(runtime.GOMAXPROCS(0))
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				subject := names[int(next.Add(1))%subjects]
+				i := 0
+				for pb.Next() {
+					sp.Publish(subject, i)
+					i++
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkSubscribeUnsubscribe(b *testing.B) {
+	sp := NewSubPub()
+	benchmarkBus(b, sp)
+
+	// Фоновые подписки на тот же субъект: удаление не должно зависеть от их числа
+	for i := 0; i < 10000; i++ {
+		if _, err := sp.Subscribe("bench", func(msg interface{}) {}); err != nil {
+			b.Fatalf("Subscribe failed: %v", err)
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sub, err := sp.Subscribe("bench", func(msg interface{}) {})
+		if err != nil {
+			b.Fatalf("Subscribe failed: %v", err)
+		}
+		sub.Unsubscribe()
+	}
+}
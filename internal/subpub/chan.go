@@ -0,0 +1,38 @@
+package subpub
+
+import "context"
+
+// SubscribeChan подписывается на субъект или шаблон и возвращает канал,
+// из которого читаются сообщения. bufSize задаёт ёмкость очереди подписки,
+// к которой применяется политика переполнения (WithOverflowPolicy);
+// неположительное значение означает ёмкость по умолчанию.
+// Канал закрывается после Unsubscribe или закрытия шины, поэтому его можно
+// читать через range или в select вместе с ctx.Done(). Пока канал не
+// читают, Close ждёт доставки уже поставленных в очередь сообщений.
+func (sp *subPub) SubscribeChan(subject string, bufSize int, opts ...SubscribeOption) (<-chan interface{}, Subscription, error) {
+	out := make(chan interface{})
+	sub, err := sp.subscribe(subject, nil, append(opts[:len(opts):len(opts)], WithBufferSize(bufSize), func(o *subscribeOptions) {
+		o.out = out
+	}))
+	if err != nil {
+		return nil, nil, err
+	}
+	return out, sub, nil
+}
+
+// send — обработчик канальной подписки: ждёт, пока сообщение прочитают
+// из канала, или остановки подписки.
+func (s *subscription) send(_ context.Context, env *envelope) error {
+	select {
+	case s.opts.out <- env.msg:
+	case <-s.done:
+	}
+	return nil
+}
+
+// closeOut закрывает канал канальной подписки после завершения доставки.
+func (s *subscription) closeOut() {
+	if s.opts.out != nil {
+		close(s.opts.out)
+	}
+}
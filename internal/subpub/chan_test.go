@@ -0,0 +1,121 @@
+package subpub
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSubscribeChan(t *testing.T) {
+	sp := NewSubPub()
+
+	ch, sub, err := sp.SubscribeChan("test", 10)
+	if err != nil {
+		t.Fatalf("SubscribeChan failed: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	for i := 0; i < 5; i++ {
+		sp.Publish("test", i)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for i := 0; i < 5; i++ {
+		select {
+		case msg := <-ch:
+			if msg != i {
+				t.Fatalf("got %v, want %d", msg, i)
+			}
+		case <-ctx.Done():
+			t.Fatalf("timeout waiting for message %d", i)
+		}
+	}
+}
+
+func TestSubscribeChanClosedOnUnsubscribe(t *testing.T) {
+	sp := NewSubPub()
+
+	ch, sub, err := sp.SubscribeChan("test", 10)
+	if err != nil {
+		t.Fatalf("SubscribeChan failed: %v", err)
+	}
+
+	sp.Publish("test", "unread")
+	time.Sleep(20 * time.Millisecond)
+	sub.Unsubscribe()
+
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("channel was not closed after Unsubscribe")
+		}
+	}
+}
+
+func TestSubscribeChanDrainedOnClose(t *testing.T) {
+	sp := NewSubPub()
+
+	ch, _, err := sp.SubscribeChan("test", 10)
+	if err != nil {
+		t.Fatalf("SubscribeChan failed: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		sp.Publish("test", i)
+	}
+
+	closed := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		closed <- sp.Close(ctx)
+	}()
+
+	var got []interface{}
+	for msg := range ch {
+		got = append(got, msg)
+	}
+	if len(got) != 3 {
+		t.Errorf("received %v before channel was closed, want [0 1 2]", got)
+	}
+	if err := <-closed; err != nil {
+		t.Errorf("Close: %v", err)
+	}
+}
+
+func TestSubscribeChanOverflowPolicy(t *testing.T) {
+	sp := NewSubPub()
+
+	ch, sub, err := sp.SubscribeChan("test", 2, WithOverflowPolicy(DropOldest))
+	if err != nil {
+		t.Fatalf("SubscribeChan failed: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	// Первое сообщение ждёт чтения в горутине доставки, следующие два
+	// заполняют очередь, а остальные вытесняют самые старые
+	sp.Publish("test", 0)
+	time.Sleep(20 * time.Millisecond)
+	for i := 1; i <= 5; i++ {
+		sp.Publish("test", i)
+	}
+
+	for _, want := range []int{0, 4, 5} {
+		select {
+		case msg := <-ch:
+			if msg != want {
+				t.Errorf("got %v, want %d", msg, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for message %d", want)
+		}
+	}
+	if stats := sub.Stats(); stats.Dropped != 3 {
+		t.Errorf("Dropped = %d, want 3", stats.Dropped)
+	}
+}
@@ -0,0 +1,178 @@
+package subpub
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestContextHandlerCancelledOnUnsubscribe(t *testing.T) {
+	sp := NewSubPub()
+
+	started := make(chan struct{})
+	cancelled := make(chan error, 1)
+	sub, err := sp.SubscribeContext("test", func(ctx context.Context, msg interface{}) {
+		close(started)
+		<-ctx.Done()
+		cancelled <- ctx.Err()
+	})
+	if err != nil {
+		t.Fatalf("SubscribeContext failed: %v", err)
+	}
+
+	sp.Publish("test", "long job")
+	<-started
+	sub.Unsubscribe()
+
+	select {
+	case err := <-cancelled:
+		if err != context.Canceled {
+			t.Errorf("ctx.Err() = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("handler context was not cancelled on Unsubscribe")
+	}
+}
+
+func TestContextHandlerCancelledOnCloseDeadline(t *testing.T) {
+	sp := NewSubPub()
+
+	started := make(chan struct{})
+	cancelled := make(chan error, 1)
+	_, err := sp.SubscribeContext("test", func(ctx context.Context, msg interface{}) {
+		close(started)
+		<-ctx.Done()
+		cancelled <- ctx.Err()
+	})
+	if err != nil {
+		t.Fatalf("SubscribeContext failed: %v", err)
+	}
+
+	sp.Publish("test", "long job")
+	<-started
+
+	// Обработчик завершается только после отмены контекста, а контекст
+	// отменяется, когда истекает время, отведённое Close
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := sp.Close(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Close error: got %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	select {
+	case err := <-cancelled:
+		if err != context.Canceled {
+			t.Errorf("ctx.Err() = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("handler context was not cancelled after the Close deadline")
+	}
+}
+
+func TestCloseDrainsWithLiveContext(t *testing.T) {
+	const messageCount = 10
+	sp := NewSubPub()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	errs := make(chan error, messageCount)
+	_, err := sp.SubscribeContext("test", func(ctx context.Context, msg interface{}) {
+		if msg == 0 {
+			close(started)
+			<-release
+		}
+		errs <- ctx.Err()
+	})
+	if err != nil {
+		t.Fatalf("SubscribeContext failed: %v", err)
+	}
+
+	for i := 0; i < messageCount; i++ {
+		sp.Publish("test", i)
+	}
+	<-started
+
+	closed := make(chan error, 1)
+	go func() {
+		closed <- sp.Close(context.Background())
+	}()
+	// Обработчик отпускается, когда Close уже начал дочитывать очередь
+	for !sp.(*subPub).closed.Load() {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+
+	if err := <-closed; err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	close(errs)
+
+	// Сообщения, дочитанные при закрытии, обрабатываются с действующим контекстом
+	var n int
+	for err := range errs {
+		if err != nil {
+			t.Errorf("message %d: ctx.Err() = %v, want nil", n, err)
+		}
+		n++
+	}
+	if n != messageCount {
+		t.Errorf("handled %d messages, want %d", n, messageCount)
+	}
+}
+
+func TestUnsubscribeWaitBlocksUntilHandlerReturns(t *testing.T) {
+	sp := NewSubPub()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	returned := make(chan struct{})
+	sub, err := sp.Subscribe("test", func(msg interface{}) {
+		close(started)
+		<-release
+		close(returned)
+	})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	sp.Publish("test", "msg")
+	<-started
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+	}()
+
+	if err := sub.UnsubscribeWait(context.Background()); err != nil {
+		t.Fatalf("UnsubscribeWait failed: %v", err)
+	}
+	select {
+	case <-returned:
+	default:
+		t.Error("UnsubscribeWait returned before the handler finished")
+	}
+}
+
+func TestUnsubscribeWaitDeadline(t *testing.T) {
+	sp := NewSubPub()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	sub, err := sp.Subscribe("test", func(msg interface{}) {
+		close(started)
+		<-release
+	})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	sp.Publish("test", "msg")
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := sub.UnsubscribeWait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("UnsubscribeWait: got %v, want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,37 @@
+package subpub
+
+import "context"
+
+// envelope — опубликованное сообщение вместе со служебными данными шины.
+// Создаётся один раз на публикацию и не изменяется после неё, поэтому
+// разделяется между всеми получателями.
+type envelope struct {
+	subject string
+	msg     interface{}
+	// reply — субъект для ответа, если сообщение отправлено через Request
+	reply string
+	// ordinal — порядковый номер публикации в пределах шины
+	ordinal uint64
+	// attempt — номер попытки доставки в режиме подтверждений, начиная с 1
+	attempt int
+	// published — время публикации в наносекундах Unix
+	published int64
+	// sequence — номер сообщения в пределах субъекта, 0 — номер не назначен
+	sequence uint64
+	// headers — заголовки сообщения, опубликованного через PublishMsg
+	headers map[string]string
+}
+
+// deliverFunc — внутренняя форма обработчика, к которой приводятся все
+// публичные формы обработчиков. Ошибка означает, что сообщение не обработано.
+type deliverFunc func(ctx context.Context, env *envelope) error
+
+func (cb MessageHandler) deliver(_ context.Context, env *envelope) error {
+	cb(env.msg)
+	return nil
+}
+
+func (cb ContextHandler) deliver(ctx context.Context, env *envelope) error {
+	cb(ctx, env.msg)
+	return nil
+}
@@ -0,0 +1,52 @@
+package subpub
+
+import "runtime/debug"
+
+// Filter решает, нужно ли доставлять сообщение подписке. Фильтр вызывается
+// в горутине издателя до постановки сообщения в очередь, поэтому должен
+// быть быстрым и не блокироваться. Отклонённые сообщения не занимают место
+// в очереди и учитываются в SubscriptionStats.Filtered.
+type Filter func(subject string, msg interface{}) bool
+
+// WithFilter добавляет подписке фильтр сообщений. Сообщение доставляется,
+// только если его принимают все фильтры подписки.
+// Участник группы очередей, выбранный для сообщения, проверяет его своими
+// фильтрами, поэтому участникам одной группы следует задавать одинаковые фильтры.
+func WithFilter(filter Filter) SubscribeOption {
+	return func(o *subscribeOptions) {
+		if filter != nil {
+			o.filters = append(o.filters, filter)
+		}
+	}
+}
+
+// accepts проверяет сообщение фильтрами подписки. Паника в фильтре
+// сообщается в ErrorHook шины, а сообщение считается отклонённым.
+func (s *subscription) accepts(env *envelope) (ok bool) {
+	if len(s.opts.filters) == 0 {
+		return true
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			ok = false
+			s.bus.reportError(&PanicError{
+				Subject: s.subject,
+				Msg:     env.msg,
+				Value:   r,
+				Stack:   debug.Stack(),
+			})
+		}
+		if !ok {
+			s.filtered.Add(1)
+			s.observeDrop(env, DropFiltered)
+		}
+	}()
+
+	for _, filter := range s.opts.filters {
+		if !filter(env.subject, env.msg) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,100 @@
+package subpub
+
+import (
+	"testing"
+	"time"
+)
+
+func even(_ string, msg interface{}) bool {
+	return msg.(int)%2 == 0
+}
+
+func TestFilterSkipsMessages(t *testing.T) {
+	sp := NewSubPub()
+
+	b := subscribeBlocking(t, sp, "test", WithBufferSize(2), WithFilter(even))
+	defer b.sub.Unsubscribe()
+
+	sp.Publish("test", 0)
+	<-b.started
+	// Отклонённые сообщения не занимают место в очереди
+	for i := 1; i <= 5; i++ {
+		sp.Publish("test", i)
+	}
+
+	stats := b.sub.Stats()
+	if stats.Pending != 2 || stats.Dropped != 0 || stats.Filtered != 3 {
+		t.Errorf("stats = %+v, want 2 pending, 0 dropped, 3 filtered", stats)
+	}
+
+	close(b.release)
+	b.expect(t, 0, 2, 4)
+}
+
+func TestFiltersAreCombined(t *testing.T) {
+	sp := NewSubPub()
+
+	c, sub := subscribeCollector(t, sp, "numbers.>",
+		WithFilter(even),
+		WithFilter(func(subject string, _ interface{}) bool { return subject == "numbers.small" }),
+	)
+	defer sub.Unsubscribe()
+
+	for i := 0; i < 4; i++ {
+		sp.Publish("numbers.small", i)
+		sp.Publish("numbers.large", i*100)
+	}
+
+	got := c.waitFor(t, 2)
+	time.Sleep(50 * time.Millisecond)
+	if len(got) != 2 || got[0] != 0 || got[1] != 2 {
+		t.Errorf("got %v, want [0 2]", got)
+	}
+}
+
+func TestFilterPanicRejectsMessage(t *testing.T) {
+	errs := make(chan error, 1)
+	sp := NewSubPub(WithErrorHook(func(err error) { errs <- err }))
+
+	c, sub := subscribeCollector(t, sp, "test", WithFilter(func(_ string, msg interface{}) bool {
+		if msg == "bad" {
+			panic("boom")
+		}
+		return true
+	}))
+	defer sub.Unsubscribe()
+
+	if err := sp.Publish("test", "bad"); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	sp.Publish("test", "good")
+
+	if got := c.waitFor(t, 1); got[0] != "good" {
+		t.Errorf("got %v, want [good]", got)
+	}
+	select {
+	case err := <-errs:
+		if p, ok := err.(*PanicError); !ok || p.Value != "boom" {
+			t.Errorf("error = %v, want PanicError with value boom", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("filter panic was not reported")
+	}
+}
+
+func TestFilterAppliesToRetainedMessages(t *testing.T) {
+	sp := NewSubPub(WithRetainedMessages(4))
+
+	for i := 0; i < 4; i++ {
+		sp.Publish("test", i)
+	}
+
+	c, sub := subscribeCollector(t, sp, "test", WithFilter(even))
+	defer sub.Unsubscribe()
+
+	got := c.waitFor(t, 2)
+	time.Sleep(50 * time.Millisecond)
+	if len(got) != 2 || got[0] != 0 || got[1] != 2 {
+		t.Errorf("got %v, want [0 2]", got)
+	}
+}
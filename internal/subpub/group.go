@@ -0,0 +1,33 @@
+package subpub
+
+import "sync/atomic"
+
+// queueGroup — группа подписок на один шаблон, между участниками которой
+// сообщения распределяются так, что каждое получает ровно один из них.
+// Состав группы меняется под блокировкой записи, pick вызывается под блокировкой чтения.
+type queueGroup struct {
+	members []*subscription
+	next    atomic.Uint32
+}
+
+// pick выбирает наименее загруженного участника, отдавая предпочтение
+// неприостановленным. Обход начинается с позиции round-robin, поэтому
+// при равной загрузке сообщения распределяются по кругу.
+func (g *queueGroup) pick() *subscription {
+	n := len(g.members)
+	start := int(g.next.Add(1)-1) % n
+
+	var best *subscription
+	bestPending, bestPaused := 0, false
+	for i := 0; i < n; i++ {
+		m := g.members[(start+i)%n]
+		pending, paused := m.pending(), m.paused.Load()
+		if best == nil || bestPaused && !paused || bestPaused == paused && pending < bestPending {
+			best, bestPending, bestPaused = m, pending, paused
+		}
+		if pending == 0 && !paused {
+			break
+		}
+	}
+	return best
+}
@@ -0,0 +1,189 @@
+package subpub
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSubscribeQueueDeliversToOneMember(t *testing.T) {
+	const (
+		memberCount  = 3
+		messageCount = 300
+	)
+	sp := NewSubPub()
+
+	var wg sync.WaitGroup
+	wg.Add(messageCount * 2)
+
+	counts := make([]int64, memberCount)
+	for i := 0; i < memberCount; i++ {
+		idx := i
+		sub, err := sp.SubscribeQueue("jobs", "workers", func(msg interface{}) {
+			atomic.AddInt64(&counts[idx], 1)
+			wg.Done()
+		})
+		if err != nil {
+			t.Fatalf("SubscribeQueue failed: %v", err)
+		}
+		defer sub.Unsubscribe()
+	}
+
+	var broadcast int64
+	sub, err := sp.Subscribe("jobs", func(msg interface{}) {
+		atomic.AddInt64(&broadcast, 1)
+		wg.Done()
+	})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	for i := 0; i < messageCount; i++ {
+		if err := sp.Publish("jobs", i); err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+	}
+
+	waitGroup(t, &wg, time.Second)
+	time.Sleep(50 * time.Millisecond)
+
+	var total int64
+	for i := range counts {
+		n := atomic.LoadInt64(&counts[i])
+		if n == 0 {
+			t.Errorf("member %d received no messages", i)
+		}
+		total += n
+	}
+	if total != messageCount {
+		t.Errorf("group received %d messages, want %d", total, messageCount)
+	}
+	if n := atomic.LoadInt64(&broadcast); n != messageCount {
+		t.Errorf("broadcast subscriber received %d messages, want %d", n, messageCount)
+	}
+}
+
+func TestSubscribeQueueSeparateGroups(t *testing.T) {
+	sp := NewSubPub()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	var a, b int64
+	for _, g := range []struct {
+		name    string
+		counter *int64
+	}{{"a", &a}, {"b", &b}} {
+		counter := g.counter
+		for i := 0; i < 2; i++ {
+			sub, err := sp.SubscribeQueue("jobs.*", g.name, func(msg interface{}) {
+				atomic.AddInt64(counter, 1)
+				wg.Done()
+			})
+			if err != nil {
+				t.Fatalf("SubscribeQueue failed: %v", err)
+			}
+			defer sub.Unsubscribe()
+		}
+	}
+
+	if err := sp.Publish("jobs.email", "msg"); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	waitGroup(t, &wg, time.Second)
+	time.Sleep(50 * time.Millisecond)
+
+	if atomic.LoadInt64(&a) != 1 || atomic.LoadInt64(&b) != 1 {
+		t.Errorf("groups received a=%d b=%d, want 1 each", a, b)
+	}
+}
+
+func TestSubscribeQueueRebalancesOnUnsubscribe(t *testing.T) {
+	const messageCount = 10
+	sp := NewSubPub()
+
+	release := make(chan struct{})
+	var mu sync.Mutex
+	received := make(map[interface{}]string)
+	handler := func(name string) MessageHandler {
+		return func(msg interface{}) {
+			<-release
+			mu.Lock()
+			received[msg] = name
+			mu.Unlock()
+		}
+	}
+
+	first, err := sp.SubscribeQueue("jobs", "workers", handler("first"))
+	if err != nil {
+		t.Fatalf("SubscribeQueue failed: %v", err)
+	}
+	second, err := sp.SubscribeQueue("jobs", "workers", handler("second"))
+	if err != nil {
+		t.Fatalf("SubscribeQueue failed: %v", err)
+	}
+	defer second.Unsubscribe()
+
+	for i := 0; i < messageCount; i++ {
+		if err := sp.Publish("jobs", i); err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	// Ожидающие сообщения первого участника переходят ко второму
+	first.Unsubscribe()
+	close(release)
+
+	deadline := time.After(time.Second)
+	for {
+		mu.Lock()
+		n := len(received)
+		mu.Unlock()
+		if n == messageCount {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("received %d messages, want %d", n, messageCount)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	var fromFirst int
+	for _, name := range received {
+		if name == "first" {
+			fromFirst++
+		}
+	}
+	// Первый участник успевает обработать только сообщение, которое выполнялось в момент отписки
+	if fromFirst > 1 {
+		t.Errorf("unsubscribed member handled %d messages, want at most 1", fromFirst)
+	}
+}
+
+func TestSubscribeQueueInvalidGroup(t *testing.T) {
+	sp := NewSubPub()
+	if _, err := sp.SubscribeQueue("jobs", "", func(msg interface{}) {}); err != ErrInvalidQueueGroup {
+		t.Errorf("SubscribeQueue with empty group: got %v, want %v", err, ErrInvalidQueueGroup)
+	}
+}
+
+func waitGroup(t *testing.T, wg *sync.WaitGroup, timeout time.Duration) {
+	t.Helper()
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		t.Fatal("timeout waiting for handlers")
+	}
+}
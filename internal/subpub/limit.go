@@ -0,0 +1,107 @@
+package subpub
+
+import (
+	"math"
+	"time"
+)
+
+// WithRateLimit ограничивает скорость доставки сообщений обработчику
+// подписки: в среднем не больше rate сообщений в секунду с накоплением
+// до burst сообщений. Пока обработчик ждёт разрешения, сообщения копятся
+// в очереди подписки, а при её переполнении действует политика переполнения.
+// Неположительный rate отключает ограничение, burst меньше 1 считается равным 1.
+func WithRateLimit(rate float64, burst int) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.rate = rate
+		o.burst = max(burst, 1)
+	}
+}
+
+// WithMaxInFlight разрешает до n одновременных вызовов обработчика подписки.
+// По умолчанию n = 1: сообщения обрабатываются последовательно в порядке
+// публикации. При n > 1 порядок завершения обработки не гарантируется.
+// Сообщения, для которых нет свободного слота, остаются в очереди подписки.
+// Значения меньше 1 игнорируются.
+func WithMaxInFlight(n int) SubscribeOption {
+	return func(o *subscribeOptions) {
+		if n > 0 {
+			o.maxInFlight = n
+		}
+	}
+}
+
+// tokenBucket — ограничитель скорости по алгоритму маркерной корзины.
+// Используется только горутиной доставки подписки.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// reserve забирает маркер и возвращает, сколько нужно подождать, прежде чем им воспользоваться.
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// acquire ждёт разрешения на очередной вызов обработчика: маркера
+// ограничителя скорости и свободного слота. Возвращает false, если
+// подписка была остановлена.
+func (s *subscription) acquire() bool {
+	if s.limiter != nil {
+		if wait := s.limiter.reserve(time.Now()); wait > 0 && !s.wait(wait) {
+			return false
+		}
+	}
+	if s.slots != nil {
+		select {
+		case s.slots <- struct{}{}:
+		case <-s.done:
+			return false
+		}
+	}
+	return true
+}
+
+// release освобождает слот, занятый acquire.
+func (s *subscription) release() {
+	if s.slots != nil {
+		<-s.slots
+	}
+}
+
+// dispatch вызывает обработчик в горутине доставки или, если разрешены
+// параллельные вызовы, в отдельной горутине.
+func (s *subscription) dispatch(env *envelope) {
+	if s.slots == nil {
+		s.inFlight.Add(1)
+		s.invoke(env)
+		s.inFlight.Add(-1)
+		return
+	}
+
+	s.inFlight.Add(1)
+	s.handlers.Add(1)
+	go func() {
+		defer s.handlers.Done()
+		defer s.release()
+		defer s.inFlight.Add(-1)
+		s.invoke(env)
+	}()
+}
@@ -0,0 +1,137 @@
+package subpub
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	sp := NewSubPub()
+
+	c, sub := subscribeCollector(t, sp, "test", WithRateLimit(50, 1))
+	defer sub.Unsubscribe()
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		sp.Publish("test", i)
+	}
+	c.waitFor(t, 5)
+
+	// Первое сообщение доставляется сразу, остальные — с интервалом 20ms
+	if elapsed := time.Since(start); elapsed < 70*time.Millisecond {
+		t.Errorf("5 messages delivered in %v, want at least 80ms at 50 msg/s", elapsed)
+	}
+}
+
+func TestRateLimitBurst(t *testing.T) {
+	sp := NewSubPub()
+
+	c, sub := subscribeCollector(t, sp, "test", WithRateLimit(1, 3))
+	defer sub.Unsubscribe()
+
+	for i := 0; i < 4; i++ {
+		sp.Publish("test", i)
+	}
+
+	c.waitFor(t, 3)
+	time.Sleep(100 * time.Millisecond)
+	if stats := sub.Stats(); stats.Delivered != 3 || stats.Pending != 1 {
+		t.Errorf("stats = %+v, want 3 delivered, 1 pending", stats)
+	}
+}
+
+func TestRateLimitOverflow(t *testing.T) {
+	sp := NewSubPub()
+
+	_, sub := subscribeCollector(t, sp, "test", WithRateLimit(1, 1), WithBufferSize(2))
+	defer sub.Unsubscribe()
+
+	sp.Publish("test", 0)
+	time.Sleep(20 * time.Millisecond)
+	for i := 1; i <= 4; i++ {
+		sp.Publish("test", i)
+	}
+
+	// Пока обработчик ждёт маркер, сообщения копятся в очереди и отбрасываются при её переполнении
+	if stats := sub.Stats(); stats.Delivered != 1 || stats.Pending != 2 || stats.Dropped != 2 {
+		t.Errorf("stats = %+v, want 1 delivered, 2 pending, 2 dropped", stats)
+	}
+}
+
+func TestMaxInFlight(t *testing.T) {
+	sp := NewSubPub()
+
+	started := make(chan interface{}, 10)
+	release := make(chan struct{})
+	sub, err := sp.Subscribe("test", func(msg interface{}) {
+		started <- msg
+		<-release
+	}, WithMaxInFlight(3))
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		sp.Publish("test", i)
+	}
+	for i := 0; i < 3; i++ {
+		select {
+		case <-started:
+		case <-time.After(time.Second):
+			t.Fatalf("%d handlers started, want 3", i)
+		}
+	}
+	select {
+	case msg := <-started:
+		t.Fatalf("handler for %v started above the limit", msg)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if stats := sub.Stats(); stats.InFlight != 3 || stats.Pending != 2 {
+		t.Errorf("stats = %+v, want 3 in flight, 2 pending", stats)
+	}
+
+	close(release)
+	for i := 0; i < 2; i++ {
+		select {
+		case <-started:
+		case <-time.After(time.Second):
+			t.Fatal("remaining messages were not delivered")
+		}
+	}
+}
+
+func TestUnsubscribeWaitWaitsForInFlightHandlers(t *testing.T) {
+	sp := NewSubPub()
+
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	finished := make(chan struct{}, 2)
+	sub, err := sp.Subscribe("test", func(msg interface{}) {
+		started <- struct{}{}
+		<-release
+		finished <- struct{}{}
+	}, WithMaxInFlight(2))
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	sp.Publish("test", 1)
+	sp.Publish("test", 2)
+	<-started
+	<-started
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := sub.UnsubscribeWait(ctx); err != nil {
+		t.Fatalf("UnsubscribeWait: %v", err)
+	}
+	if len(finished) != 2 {
+		t.Errorf("UnsubscribeWait returned with %d of 2 handlers finished", len(finished))
+	}
+}
@@ -0,0 +1,73 @@
+package subpub
+
+import (
+	"context"
+	"maps"
+	"strings"
+	"time"
+)
+
+// Message — сообщение вместе с метаданными публикации.
+type Message struct {
+	// Subject — субъект публикации. У подписки на шаблон позволяет узнать,
+	// какой именно субъект совпал.
+	Subject string
+	// Sequence — номер сообщения в пределах субъекта, начиная с 1. При
+	// публикации в один субъект из нескольких горутин сообщения могут прийти
+	// не в порядке номеров. Пока на субъект есть подписки, нумерация
+	// не прерывается; после того как их не осталось, шина может забыть
+	// последний номер, и нумерация начнётся заново с 1. Равен 0 у ответов
+	// на запросы и у сообщений, номер которых неизвестен, например
+	// полученных от внешнего брокера.
+	Sequence uint64
+	// Timestamp — время публикации.
+	Timestamp time.Time
+	// Headers — заголовки, переданные в PublishMsg. Карта общая для всех
+	// получателей сообщения и не должна изменяться.
+	Headers map[string]string
+	// Payload — само сообщение, которое получают обработчики MessageHandler.
+	Payload interface{}
+}
+
+// MsgHandler — обработчик, получающий сообщение вместе с метаданными публикации.
+type MsgHandler func(msg *Message)
+
+func (cb MsgHandler) deliver(_ context.Context, env *envelope) error {
+	cb(env.message())
+	return nil
+}
+
+// SubscribeMsg подписывает обработчик, получающий сообщения вместе
+// с метаданными публикации. Каждая доставка получает собственный *Message.
+func (sp *subPub) SubscribeMsg(subject string, cb MsgHandler, opts ...SubscribeOption) (Subscription, error) {
+	return subscribed(sp.subscribe(subject, cb.deliver, opts))
+}
+
+// PublishMsg публикует msg.Payload в субъект msg.Subject с заголовками
+// msg.Headers. Sequence и Timestamp назначает шина, значения в msg
+// не учитываются. Обработчики MessageHandler получают только Payload.
+func (sp *subPub) PublishMsg(msg *Message) error {
+	_, err := sp.publish(&envelope{
+		subject: msg.Subject,
+		msg:     msg.Payload,
+		headers: maps.Clone(msg.Headers),
+	})
+	return err
+}
+
+// message создаёт Message для доставки конверта одному получателю.
+func (env *envelope) message() *Message {
+	return &Message{
+		Subject:   env.subject,
+		Sequence:  env.sequence,
+		Timestamp: time.Unix(0, env.published),
+		Headers:   env.headers,
+		Payload:   env.msg,
+	}
+}
+
+// sequenced сообщает, нужно ли назначать сообщению номер. Ответы на запросы
+// публикуются в уникальные субъекты, и счётчики для них не заводятся.
+func sequenced(env *envelope) bool {
+	return !strings.HasPrefix(env.subject, inboxRoot+tokenSeparator)
+}
@@ -0,0 +1,233 @@
+package subpub
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// subscribeMessages подписывает на subject обработчик, складывающий
+// полученные сообщения в канал.
+func subscribeMessages(t *testing.T, sp SubPub, subject string) (<-chan *Message, Subscription) {
+	t.Helper()
+
+	received := make(chan *Message, 100)
+	sub, err := sp.SubscribeMsg(subject, func(msg *Message) {
+		received <- msg
+	})
+	if err != nil {
+		t.Fatalf("SubscribeMsg failed: %v", err)
+	}
+	return received, sub
+}
+
+func receiveMessage(t *testing.T, received <-chan *Message) *Message {
+	t.Helper()
+
+	select {
+	case msg := <-received:
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for message")
+		return nil
+	}
+}
+
+func TestSubscribeMsgMetadata(t *testing.T) {
+	sp := NewSubPub()
+	received, sub := subscribeMessages(t, sp, "orders.*")
+	defer sub.Unsubscribe()
+
+	before := time.Now()
+	if err := sp.Publish("orders.created", "order"); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	msg := receiveMessage(t, received)
+	if msg.Subject != "orders.created" {
+		t.Errorf("Subject: got %q, want %q", msg.Subject, "orders.created")
+	}
+	if msg.Payload != "order" {
+		t.Errorf("Payload: got %v, want %q", msg.Payload, "order")
+	}
+	if msg.Sequence != 1 {
+		t.Errorf("Sequence: got %d, want 1", msg.Sequence)
+	}
+	if msg.Timestamp.Before(before) || msg.Timestamp.After(time.Now()) {
+		t.Errorf("Timestamp %v is outside of the publish interval", msg.Timestamp)
+	}
+	if msg.Headers != nil {
+		t.Errorf("Headers: got %v, want nil", msg.Headers)
+	}
+}
+
+func TestSubscribeMsgSequencePerSubject(t *testing.T) {
+	sp := NewSubPub()
+	received, sub := subscribeMessages(t, sp, "orders.>")
+	defer sub.Unsubscribe()
+
+	subjects := []string{"orders.created", "orders.paid", "orders.created", "orders.created", "orders.paid"}
+	for _, subject := range subjects {
+		if err := sp.Publish(subject, subject); err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+	}
+
+	want := []uint64{1, 1, 2, 3, 2}
+	for i := range subjects {
+		msg := receiveMessage(t, received)
+		if msg.Subject != subjects[i] || msg.Sequence != want[i] {
+			t.Errorf("message %d: got %s #%d, want %s #%d", i, msg.Subject, msg.Sequence, subjects[i], want[i])
+		}
+	}
+}
+
+func TestSequencesOfUnsubscribedSubjectsAreReleased(t *testing.T) {
+	sp := NewSubPub().(*subPub)
+	exact, sub := subscribeMessages(t, sp, "orders.created")
+	defer sub.Unsubscribe()
+	wildcard, wsub := subscribeMessages(t, sp, "users.>")
+	defer wsub.Unsubscribe()
+
+	sp.Publish("orders.created", 1)
+	sp.Publish("users.created", 1)
+	receiveMessage(t, exact)
+	receiveMessage(t, wildcard)
+
+	for i := 0; i < 10000; i++ {
+		sp.Publish(fmt.Sprintf("events.%d", i), i)
+	}
+
+	total := 0
+	for i := range sp.routes.shards {
+		s := &sp.routes.shards[i]
+		s.mu.RLock()
+		n, limit := len(s.sequences), s.seqLimit
+		s.mu.RUnlock()
+		if n > limit {
+			t.Errorf("shard %d keeps %d sequences, limit %d", i, n, limit)
+		}
+		total += n
+	}
+	if total > shardCount*minSequenceLimit {
+		t.Errorf("%d sequences kept after publishing to 10000 subjects", total)
+	}
+
+	// Нумерация субъектов с подписками не прерывается
+	sp.Publish("orders.created", 2)
+	sp.Publish("users.created", 2)
+	if msg := receiveMessage(t, exact); msg.Sequence != 2 {
+		t.Errorf("orders.created Sequence: got %d, want 2", msg.Sequence)
+	}
+	if msg := receiveMessage(t, wildcard); msg.Sequence != 2 {
+		t.Errorf("users.created Sequence: got %d, want 2", msg.Sequence)
+	}
+}
+
+func TestSequencesOfRetainedSubjectsAreKept(t *testing.T) {
+	sp := NewSubPub(WithRetainedMessages(3))
+
+	for i := 1; i <= 5; i++ {
+		sp.Publish("orders", i)
+	}
+	// Публикации в другие субъекты запускают очистку счётчиков
+	for i := 0; i < 10000; i++ {
+		sp.Publish(fmt.Sprintf("events.%d", i), i)
+	}
+
+	received, sub := subscribeMessages(t, sp, "orders")
+	defer sub.Unsubscribe()
+	sp.Publish("orders", 6)
+
+	for _, want := range []uint64{3, 4, 5, 6} {
+		if msg := receiveMessage(t, received); msg.Sequence != want {
+			t.Errorf("message %v: got Sequence %d, want %d", msg.Payload, msg.Sequence, want)
+		}
+	}
+}
+
+func TestPublishMsgHeaders(t *testing.T) {
+	sp := NewSubPub()
+	messages, sub := subscribeMessages(t, sp, "orders")
+	defer sub.Unsubscribe()
+
+	payloads := make(chan interface{}, 1)
+	plain, err := sp.Subscribe("orders", func(msg interface{}) {
+		payloads <- msg
+	})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer plain.Unsubscribe()
+
+	headers := map[string]string{"region": "eu"}
+	if err := sp.PublishMsg(&Message{Subject: "orders", Headers: headers, Payload: 42, Sequence: 100}); err != nil {
+		t.Fatalf("PublishMsg failed: %v", err)
+	}
+	// Изменение карты после публикации не влияет на доставленное сообщение
+	headers["region"] = "us"
+
+	msg := receiveMessage(t, messages)
+	if msg.Headers["region"] != "eu" {
+		t.Errorf("Headers: got %v, want region=eu", msg.Headers)
+	}
+	if msg.Payload != 42 || msg.Sequence != 1 {
+		t.Errorf("got payload %v #%d, want 42 #1", msg.Payload, msg.Sequence)
+	}
+
+	select {
+	case got := <-payloads:
+		if got != 42 {
+			t.Errorf("plain handler got %v, want 42", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for plain handler")
+	}
+}
+
+func TestPublishMsgInvalidSubject(t *testing.T) {
+	sp := NewSubPub()
+	if err := sp.PublishMsg(&Message{Subject: "orders.*", Payload: 1}); err != ErrWildcardSubject {
+		t.Errorf("got %v, want %v", err, ErrWildcardSubject)
+	}
+}
+
+func TestSubscribeMsgRepliesHaveNoSequence(t *testing.T) {
+	sp := NewSubPub()
+	sub, err := sp.SubscribeRequests("echo", func(msg interface{}, respond Responder) {
+		respond(msg)
+	})
+	if err != nil {
+		t.Fatalf("SubscribeRequests failed: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	replies, rsub := subscribeMessages(t, sp, inboxRoot+".>")
+	defer rsub.Unsubscribe()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := sp.Request(ctx, "echo", 1); err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+
+	if msg := receiveMessage(t, replies); msg.Sequence != 0 {
+		t.Errorf("reply Sequence: got %d, want 0", msg.Sequence)
+	}
+}
+
+func TestScopedSubscribeMsgStripsPrefix(t *testing.T) {
+	sp := NewSubPub()
+	billing := sp.Scoped("billing.")
+	received, sub := subscribeMessages(t, billing, "invoices.*")
+	defer sub.Unsubscribe()
+
+	if err := billing.PublishMsg(&Message{Subject: "invoices.paid", Payload: 1}); err != nil {
+		t.Fatalf("PublishMsg failed: %v", err)
+	}
+
+	if msg := receiveMessage(t, received); msg.Subject != "invoices.paid" {
+		t.Errorf("Subject: got %q, want %q", msg.Subject, "invoices.paid")
+	}
+}
@@ -0,0 +1,261 @@
+package subpub
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultLatencyBuckets — верхние границы корзин гистограммы задержек доставки по умолчанию.
+var DefaultLatencyBuckets = []time.Duration{
+	100 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+// Metrics — наблюдатель, накапливающий счётчики событий и гистограммы
+// задержек доставки в разрезе субъектов и шаблонов подписок. Текущие
+// значения возвращает Snapshot. Подключается к шине через WithObserver.
+type Metrics struct {
+	buckets []time.Duration
+
+	published    atomic.Uint64
+	unrouted     atomic.Uint64
+	subscription atomic.Int64
+
+	mu       sync.RWMutex
+	patterns map[string]*patternMetrics
+}
+
+// NewMetrics создаёт Metrics с заданными верхними границами корзин
+// гистограммы задержек; без аргументов используются DefaultLatencyBuckets.
+func NewMetrics(buckets ...time.Duration) *Metrics {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	buckets = append([]time.Duration(nil), buckets...)
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i] < buckets[j] })
+
+	return &Metrics{
+		buckets:  buckets,
+		patterns: make(map[string]*patternMetrics),
+	}
+}
+
+type patternMetrics struct {
+	subscriptions atomic.Int64
+	enqueued      atomic.Uint64
+	delivered     atomic.Uint64
+	failed        atomic.Uint64
+	dropped       [len(dropReasonNames)]atomic.Uint64
+	latency       *histogram
+}
+
+// pattern возвращает счётчики шаблона, создавая их при первом обращении.
+func (m *Metrics) pattern(pattern string) *patternMetrics {
+	m.mu.RLock()
+	p, ok := m.patterns[pattern]
+	m.mu.RUnlock()
+	if ok {
+		return p
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if p, ok = m.patterns[pattern]; !ok {
+		p = &patternMetrics{latency: newHistogram(m.buckets)}
+		m.patterns[pattern] = p
+	}
+	return p
+}
+
+// OnPublish учитывает публикацию.
+func (m *Metrics) OnPublish(_ string, receivers int) {
+	m.published.Add(1)
+	if receivers == 0 {
+		m.unrouted.Add(1)
+	}
+}
+
+// OnEnqueue учитывает постановку сообщения в очередь подписки.
+func (m *Metrics) OnEnqueue(info DeliveryInfo) {
+	m.pattern(info.Pattern).enqueued.Add(1)
+}
+
+// OnDeliver учитывает вызов обработчика и его задержку.
+func (m *Metrics) OnDeliver(info DeliveryInfo, latency time.Duration, err error) {
+	p := m.pattern(info.Pattern)
+	p.delivered.Add(1)
+	if err != nil {
+		p.failed.Add(1)
+	}
+	p.latency.observe(latency)
+}
+
+// OnDrop учитывает недоставленное сообщение.
+func (m *Metrics) OnDrop(info DeliveryInfo, reason DropReason) {
+	if reason >= 0 && int(reason) < len(dropReasonNames) {
+		m.pattern(info.Pattern).dropped[reason].Add(1)
+	}
+}
+
+// OnSubscribe учитывает новую подписку.
+func (m *Metrics) OnSubscribe(pattern, _ string) {
+	m.subscription.Add(1)
+	m.pattern(pattern).subscriptions.Add(1)
+}
+
+// OnUnsubscribe учитывает завершение подписки.
+func (m *Metrics) OnUnsubscribe(pattern, _ string) {
+	m.subscription.Add(-1)
+	m.pattern(pattern).subscriptions.Add(-1)
+}
+
+// MetricsSnapshot — снимок значений Metrics.
+type MetricsSnapshot struct {
+	// Published — число публикаций
+	Published uint64
+	// Unrouted — число публикаций, у которых не нашлось ни одной подписки
+	Unrouted uint64
+	// Subscriptions — число активных подписок
+	Subscriptions int64
+	// Patterns — метрики по субъектам и шаблонам подписок. Шаблон остаётся
+	// в снимке и после завершения всех его подписок.
+	Patterns map[string]PatternMetrics
+}
+
+// PatternMetrics — метрики подписок на один субъект или шаблон.
+type PatternMetrics struct {
+	// Subscriptions — число активных подписок
+	Subscriptions int64
+	// Enqueued — число сообщений, поставленных в очереди подписок
+	Enqueued uint64
+	// Delivered — число вызовов обработчика, включая повторные
+	Delivered uint64
+	// Failed — число вызовов обработчика, завершившихся ошибкой или паникой
+	Failed uint64
+	// Dropped — число недоставленных сообщений по причинам
+	Dropped map[DropReason]uint64
+	// Latency — распределение времени от публикации до завершения обработки
+	Latency Histogram
+}
+
+// Snapshot возвращает текущие значения метрик.
+func (m *Metrics) Snapshot() MetricsSnapshot {
+	snapshot := MetricsSnapshot{
+		Published:     m.published.Load(),
+		Unrouted:      m.unrouted.Load(),
+		Subscriptions: m.subscription.Load(),
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	snapshot.Patterns = make(map[string]PatternMetrics, len(m.patterns))
+	for pattern, p := range m.patterns {
+		pm := PatternMetrics{
+			Subscriptions: p.subscriptions.Load(),
+			Enqueued:      p.enqueued.Load(),
+			Delivered:     p.delivered.Load(),
+			Failed:        p.failed.Load(),
+			Dropped:       make(map[DropReason]uint64),
+			Latency:       p.latency.snapshot(),
+		}
+		for reason := range p.dropped {
+			if n := p.dropped[reason].Load(); n > 0 {
+				pm.Dropped[DropReason(reason)] = n
+			}
+		}
+		snapshot.Patterns[pattern] = pm
+	}
+	return snapshot
+}
+
+// Histogram — снимок гистограммы задержек.
+type Histogram struct {
+	// Bounds — верхние границы корзин по возрастанию
+	Bounds []time.Duration
+	// Counts — число наблюдений в каждой корзине; последняя корзина
+	// содержит наблюдения больше последней границы
+	Counts []uint64
+	// Count — общее число наблюдений
+	Count uint64
+	// Sum — сумма наблюдений
+	Sum time.Duration
+	// Max — наибольшее наблюдение
+	Max time.Duration
+}
+
+// Mean возвращает среднюю задержку или 0, если наблюдений не было.
+func (h Histogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+// Quantile оценивает квантиль q из диапазона [0, 1] сверху: возвращает
+// верхнюю границу корзины, в которую он попадает, или Max для последней корзины.
+func (h Histogram) Quantile(q float64) time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	rank := max(uint64(math.Ceil(q*float64(h.Count))), 1)
+	var seen uint64
+	for i, n := range h.Counts {
+		seen += n
+		if seen >= rank && i < len(h.Bounds) {
+			return min(h.Bounds[i], h.Max)
+		}
+	}
+	return h.Max
+}
+
+// histogram накапливает наблюдения без блокировок.
+type histogram struct {
+	bounds []time.Duration
+	counts []atomic.Uint64
+	sum    atomic.Int64
+	max    atomic.Int64
+}
+
+func newHistogram(bounds []time.Duration) *histogram {
+	return &histogram{
+		bounds: bounds,
+		counts: make([]atomic.Uint64, len(bounds)+1),
+	}
+}
+
+func (h *histogram) observe(d time.Duration) {
+	i := sort.Search(len(h.bounds), func(i int) bool { return d <= h.bounds[i] })
+	h.counts[i].Add(1)
+	h.sum.Add(int64(d))
+	for {
+		cur := h.max.Load()
+		if int64(d) <= cur || h.max.CompareAndSwap(cur, int64(d)) {
+			break
+		}
+	}
+}
+
+func (h *histogram) snapshot() Histogram {
+	snapshot := Histogram{
+		Bounds: h.bounds,
+		Counts: make([]uint64, len(h.counts)),
+		Sum:    time.Duration(h.sum.Load()),
+		Max:    time.Duration(h.max.Load()),
+	}
+	for i := range h.counts {
+		snapshot.Counts[i] = h.counts[i].Load()
+		snapshot.Count += snapshot.Counts[i]
+	}
+	return snapshot
+}
@@ -0,0 +1,79 @@
+package subpub
+
+// PublishFunc публикует сообщение в субъект.
+type PublishFunc func(subject string, msg interface{}) error
+
+// PublishInterceptor перехватывает публикацию до рассылки подписчикам.
+// Он может изменить субъект или сообщение, передав их в next, или
+// отклонить публикацию, вернув ошибку без вызова next; эта ошибка
+// возвращается из Publish.
+type PublishInterceptor func(subject string, msg interface{}, next PublishFunc) error
+
+// DeliveryInfo описывает доставку сообщения конкретной подписке.
+type DeliveryInfo struct {
+	// Subject — субъект, в который было опубликовано сообщение
+	Subject string
+	// Pattern — субъект или шаблон подписки
+	Pattern string
+	// Group — группа очередей подписки, пустая для обычной подписки
+	Group string
+}
+
+// DeliverFunc передаёт сообщение обработчику подписки.
+type DeliverFunc func(msg interface{}) error
+
+// DeliveryInterceptor перехватывает доставку сообщения обработчику.
+// Он может изменить сообщение, передав его в next, или отклонить доставку,
+// вернув ошибку без вызова next; такая ошибка передаётся в ErrorHook шины.
+type DeliveryInterceptor func(info DeliveryInfo, msg interface{}, next DeliverFunc) error
+
+// WithPublishInterceptors добавляет перехватчики публикации. Первый
+// перехватчик вызывается первым и оборачивает все последующие.
+func WithPublishInterceptors(interceptors ...PublishInterceptor) Option {
+	return func(o *options) {
+		o.publishInterceptors = append(o.publishInterceptors, interceptors...)
+	}
+}
+
+// WithDeliveryInterceptors добавляет перехватчики доставки. Первый
+// перехватчик вызывается первым и оборачивает все последующие.
+func WithDeliveryInterceptors(interceptors ...DeliveryInterceptor) Option {
+	return func(o *options) {
+		o.deliveryInterceptors = append(o.deliveryInterceptors, interceptors...)
+	}
+}
+
+func chainPublish(interceptors []PublishInterceptor, final PublishFunc) PublishFunc {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], final
+		final = func(subject string, msg interface{}) error {
+			return interceptor(subject, msg, next)
+		}
+	}
+	return final
+}
+
+func chainDelivery(interceptors []DeliveryInterceptor, info DeliveryInfo, final DeliverFunc) DeliverFunc {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], final
+		final = func(msg interface{}) error {
+			return interceptor(info, msg, next)
+		}
+	}
+	return final
+}
+
+// deliver пропускает сообщение через перехватчики доставки и вызывает обработчик.
+// Возвращает ошибку обработчика или перехватчика, отклонившего доставку.
+func (s *subscription) deliver(env *envelope) error {
+	interceptors := s.bus.opts.deliveryInterceptors
+	if len(interceptors) == 0 {
+		return s.handler(s.ctx, env)
+	}
+
+	return chainDelivery(interceptors, s.info(env), func(msg interface{}) error {
+		modified := *env
+		modified.msg = msg
+		return s.handler(s.ctx, &modified)
+	})(env.msg)
+}
@@ -0,0 +1,183 @@
+package subpub
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPublishInterceptorsOrderAndMutation(t *testing.T) {
+	var mu sync.Mutex
+	var calls []string
+	record := func(name string) PublishInterceptor {
+		return func(subject string, msg interface{}, next PublishFunc) error {
+			mu.Lock()
+			calls = append(calls, name)
+			mu.Unlock()
+			return next(subject, msg.(string)+"+"+name)
+		}
+	}
+
+	sp := NewSubPub(WithPublishInterceptors(record("first"), record("second")))
+
+	received := make(chan interface{}, 1)
+	sub, err := sp.Subscribe("test", func(msg interface{}) {
+		received <- msg
+	})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	if err := sp.Publish("test", "msg"); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	select {
+	case got := <-received:
+		if got != "msg+first+second" {
+			t.Errorf("got %v, want %v", got, "msg+first+second")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for message")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if strings.Join(calls, ",") != "first,second" {
+		t.Errorf("interceptors called in order %v, want [first second]", calls)
+	}
+}
+
+func TestPublishInterceptorRejects(t *testing.T) {
+	errInvalid := errors.New("payload must be a string")
+	sp := NewSubPub(WithPublishInterceptors(func(subject string, msg interface{}, next PublishFunc) error {
+		if _, ok := msg.(string); !ok {
+			return errInvalid
+		}
+		return next(subject, msg)
+	}))
+
+	received := make(chan interface{}, 2)
+	sub, err := sp.Subscribe("test", func(msg interface{}) {
+		received <- msg
+	})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	if err := sp.Publish("test", 42); err != errInvalid {
+		t.Errorf("Publish: got %v, want %v", err, errInvalid)
+	}
+	if err := sp.Publish("test", "valid"); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	select {
+	case got := <-received:
+		if got != "valid" {
+			t.Errorf("got %v, want %v", got, "valid")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for message")
+	}
+
+	select {
+	case got := <-received:
+		t.Errorf("rejected message was delivered: %v", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestPublishInterceptorAppliesToRequests(t *testing.T) {
+	sp := NewSubPub(WithPublishInterceptors(func(subject string, msg interface{}, next PublishFunc) error {
+		if s, ok := msg.(string); ok {
+			msg = strings.ToUpper(s)
+		}
+		return next(subject, msg)
+	}))
+
+	sub, err := sp.SubscribeRequests("echo", func(msg interface{}, respond Responder) {
+		respond(msg.(string) + "!")
+	})
+	if err != nil {
+		t.Fatalf("SubscribeRequests failed: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	reply, err := sp.Request(ctx, "echo", "hi")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	// Перехватчик применяется и к запросу, и к ответу
+	if reply != "HI!" {
+		t.Errorf("got %v, want %v", reply, "HI!")
+	}
+}
+
+func TestDeliveryInterceptors(t *testing.T) {
+	errRedacted := errors.New("secret messages are not delivered")
+	infos := make(chan DeliveryInfo, 10)
+	hookErrs := make(chan error, 10)
+
+	sp := NewSubPub(
+		WithErrorHook(func(err error) {
+			hookErrs <- err
+		}),
+		WithDeliveryInterceptors(
+			func(info DeliveryInfo, msg interface{}, next DeliverFunc) error {
+				infos <- info
+				return next(msg)
+			},
+			func(info DeliveryInfo, msg interface{}, next DeliverFunc) error {
+				if msg == "secret" {
+					return errRedacted
+				}
+				return next(strings.ReplaceAll(msg.(string), "password", "********"))
+			},
+		),
+	)
+
+	received := make(chan interface{}, 10)
+	sub, err := sp.Subscribe("users.*", func(msg interface{}) {
+		received <- msg
+	}, WithQueueGroup("auditors"))
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	sp.Publish("users.login", "secret")
+	sp.Publish("users.login", "password=hunter2")
+
+	select {
+	case got := <-received:
+		if got != "********=hunter2" {
+			t.Errorf("got %v, want redacted message", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for message")
+	}
+
+	select {
+	case err := <-hookErrs:
+		if err != errRedacted {
+			t.Errorf("error hook got %v, want %v", err, errRedacted)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("rejected delivery was not reported")
+	}
+
+	info := <-infos
+	want := DeliveryInfo{Subject: "users.login", Pattern: "users.*", Group: "auditors"}
+	if info != want {
+		t.Errorf("DeliveryInfo = %+v, want %+v", info, want)
+	}
+}
@@ -0,0 +1,161 @@
+package subpub
+
+import (
+	"fmt"
+	"time"
+)
+
+// Observer получает уведомления о событиях шины и позволяет подключить
+// метрики и трассировку. Методы вызываются синхронно в горутинах издателя
+// и доставки, иногда под внутренними блокировками шины, поэтому должны быть
+// быстрыми, потокобезопасными и не должны обращаться к самой шине.
+type Observer interface {
+	// OnPublish вызывается после рассылки сообщения; receivers — число
+	// подписок, которым оно было адресовано, или -1, если получателей
+	// выбирает внешний брокер (WithTransport).
+	OnPublish(subject string, receivers int)
+	// OnEnqueue вызывается, когда сообщение поставлено в очередь подписки.
+	OnEnqueue(info DeliveryInfo)
+	// OnDeliver вызывается после того, как обработчик вернул управление.
+	// latency — время от публикации сообщения (для шины с транспортом —
+	// по часам издателя) до завершения обработки,
+	// err — ошибка обработчика или перехваченная паника.
+	OnDeliver(info DeliveryInfo, latency time.Duration, err error)
+	// OnDrop вызывается, когда сообщение не было доставлено подписке.
+	OnDrop(info DeliveryInfo, reason DropReason)
+	// OnSubscribe вызывается после создания подписки. О подписках на ответы,
+	// которые создают Request и RequestMany, наблюдатели не уведомляются.
+	OnSubscribe(pattern, group string)
+	// OnUnsubscribe вызывается после завершения подписки: отписки,
+	// принудительного отключения или закрытия шины.
+	OnUnsubscribe(pattern, group string)
+}
+
+// DropReason — причина, по которой сообщение не было доставлено подписке.
+type DropReason int
+
+const (
+	// DropOverflow — очередь подписки переполнена (политики DropNewest и DropOldest).
+	DropOverflow DropReason = iota
+	// DropTimeout — место в очереди не освободилось за время ожидания политики Block.
+	DropTimeout
+	// DropDisconnect — подписка принудительно отключена политикой Disconnect.
+	DropDisconnect
+	// DropFiltered — сообщение отклонено фильтрами подписки.
+	DropFiltered
+)
+
+var dropReasonNames = [...]string{
+	DropOverflow:   "overflow",
+	DropTimeout:    "timeout",
+	DropDisconnect: "disconnect",
+	DropFiltered:   "filtered",
+}
+
+func (r DropReason) String() string {
+	if r >= 0 && int(r) < len(dropReasonNames) {
+		return dropReasonNames[r]
+	}
+	return fmt.Sprintf("DropReason(%d)", int(r))
+}
+
+// WithObserver подключает наблюдателя событий шины. Несколько наблюдателей
+// вызываются в порядке подключения.
+func WithObserver(observer Observer) Option {
+	return func(o *options) {
+		if observer != nil {
+			o.observers = append(o.observers, observer)
+		}
+	}
+}
+
+// observers рассылает события всем подключённым наблюдателям.
+type observers []Observer
+
+func newObserver(list []Observer) Observer {
+	switch len(list) {
+	case 0:
+		return nil
+	case 1:
+		return list[0]
+	default:
+		return observers(list)
+	}
+}
+
+func (list observers) OnPublish(subject string, receivers int) {
+	for _, o := range list {
+		o.OnPublish(subject, receivers)
+	}
+}
+
+func (list observers) OnEnqueue(info DeliveryInfo) {
+	for _, o := range list {
+		o.OnEnqueue(info)
+	}
+}
+
+func (list observers) OnDeliver(info DeliveryInfo, latency time.Duration, err error) {
+	for _, o := range list {
+		o.OnDeliver(info, latency, err)
+	}
+}
+
+func (list observers) OnDrop(info DeliveryInfo, reason DropReason) {
+	for _, o := range list {
+		o.OnDrop(info, reason)
+	}
+}
+
+func (list observers) OnSubscribe(pattern, group string) {
+	for _, o := range list {
+		o.OnSubscribe(pattern, group)
+	}
+}
+
+func (list observers) OnUnsubscribe(pattern, group string) {
+	for _, o := range list {
+		o.OnUnsubscribe(pattern, group)
+	}
+}
+
+// info описывает доставку сообщения подписке.
+func (s *subscription) info(env *envelope) DeliveryInfo {
+	return DeliveryInfo{
+		Subject: env.subject,
+		Pattern: s.subject,
+		Group:   s.group,
+	}
+}
+
+// observer возвращает наблюдателей шины или nil для служебных подписок.
+func (s *subscription) observer() Observer {
+	if s.opts.unobserved {
+		return nil
+	}
+	return s.bus.observer
+}
+
+func (s *subscription) observeEnqueue(env *envelope) {
+	if o := s.observer(); o != nil {
+		o.OnEnqueue(s.info(env))
+	}
+}
+
+func (s *subscription) observeDeliver(env *envelope, err error) {
+	if o := s.observer(); o != nil {
+		o.OnDeliver(s.info(env), time.Duration(time.Now().UnixNano()-env.published), err)
+	}
+}
+
+func (s *subscription) observeDrop(env *envelope, reason DropReason) {
+	if o := s.observer(); o != nil {
+		o.OnDrop(s.info(env), reason)
+	}
+}
+
+func (s *subscription) observeUnsubscribe() {
+	if o := s.observer(); o != nil {
+		o.OnUnsubscribe(s.subject, s.group)
+	}
+}
@@ -0,0 +1,262 @@
+package subpub
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingObserver записывает события шины в виде строк.
+type recordingObserver struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recordingObserver) record(format string, args ...interface{}) {
+	r.mu.Lock()
+	r.events = append(r.events, fmt.Sprintf(format, args...))
+	r.mu.Unlock()
+}
+
+func (r *recordingObserver) OnPublish(subject string, receivers int) {
+	r.record("publish %s %d", subject, receivers)
+}
+
+func (r *recordingObserver) OnEnqueue(info DeliveryInfo) {
+	r.record("enqueue %s %s", info.Pattern, info.Subject)
+}
+
+func (r *recordingObserver) OnDeliver(info DeliveryInfo, latency time.Duration, err error) {
+	if latency <= 0 {
+		r.record("deliver %s with latency %v", info.Pattern, latency)
+		return
+	}
+	r.record("deliver %s %s %v", info.Pattern, info.Subject, err)
+}
+
+func (r *recordingObserver) OnDrop(info DeliveryInfo, reason DropReason) {
+	r.record("drop %s %s %v", info.Pattern, info.Subject, reason)
+}
+
+func (r *recordingObserver) OnSubscribe(pattern, group string) {
+	r.record("subscribe %s %s", pattern, group)
+}
+
+func (r *recordingObserver) OnUnsubscribe(pattern, group string) {
+	r.record("unsubscribe %s %s", pattern, group)
+}
+
+func (r *recordingObserver) snapshot() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.events...)
+}
+
+func TestObserverEvents(t *testing.T) {
+	rec := &recordingObserver{}
+	sp := NewSubPub(WithObserver(rec))
+
+	c, sub := subscribeCollector(t, sp, "orders.*", WithFilter(func(subject string, msg interface{}) bool {
+		return msg != "skip"
+	}))
+	sp.Publish("orders.created", "order")
+	sp.Publish("orders.created", "skip")
+	c.waitFor(t, 1)
+	if err := sub.UnsubscribeWait(context.Background()); err != nil {
+		t.Fatalf("UnsubscribeWait failed: %v", err)
+	}
+
+	want := []string{
+		"subscribe orders.* ",
+		"enqueue orders.* orders.created",
+		"publish orders.created 1",
+		"drop orders.* orders.created filtered",
+		"publish orders.created 1",
+		"unsubscribe orders.* ",
+	}
+	got := rec.snapshot()
+	// Доставка выполняется в горутине подписки и может опередить публикацию второго сообщения
+	deliver := "deliver orders.* orders.created <nil>"
+	for i, event := range got {
+		if event == deliver {
+			got = append(got[:i:i], got[i+1:]...)
+			break
+		}
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("events = %q, want %q plus %q", rec.snapshot(), want, deliver)
+	}
+}
+
+func TestObserverSubscribeComesFirst(t *testing.T) {
+	rec := &recordingObserver{}
+	sp := NewSubPub(WithObserver(rec), WithRetainedMessages(1))
+
+	sp.Publish("orders", "retained")
+	c, sub := subscribeCollector(t, sp, "orders")
+	c.waitFor(t, 1)
+	if err := sub.UnsubscribeWait(context.Background()); err != nil {
+		t.Fatalf("UnsubscribeWait failed: %v", err)
+	}
+
+	// Сохранённое сообщение ставится в очередь при создании подписки,
+	// но наблюдатели должны узнать о подписке раньше
+	want := []string{
+		"publish orders 0",
+		"subscribe orders ",
+		"enqueue orders orders",
+		"deliver orders orders <nil>",
+		"unsubscribe orders ",
+	}
+	if got := rec.snapshot(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("events = %q, want %q", got, want)
+	}
+}
+
+func TestObserverOverflowDrops(t *testing.T) {
+	rec := &recordingObserver{}
+	sp := NewSubPub(WithObserver(rec))
+
+	b := subscribeBlocking(t, sp, "test", WithBufferSize(1))
+	defer b.sub.Unsubscribe()
+
+	sp.Publish("test", 0)
+	<-b.started
+	sp.Publish("test", 1)
+	sp.Publish("test", 2)
+	close(b.release)
+	b.expect(t, 0, 1)
+
+	drops := 0
+	for _, event := range rec.snapshot() {
+		if event == "drop test test overflow" {
+			drops++
+		}
+	}
+	if drops != 1 {
+		t.Errorf("events = %q, want one overflow drop", rec.snapshot())
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	metrics := NewMetrics()
+	sp := NewSubPub(WithObserver(metrics), WithErrorHook(func(error) {}))
+
+	done := make(chan struct{})
+	sub, err := sp.Subscribe("orders.>", func(msg interface{}) {
+		switch msg {
+		case "panic":
+			panic(msg)
+		case "last":
+			close(done)
+		}
+	})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	sp.Publish("orders.created", "order")
+	sp.Publish("orders.created", "panic")
+	sp.Publish("orders.eu.updated", "last")
+	sp.Publish("users.created", "user")
+	<-done
+
+	if err := sub.UnsubscribeWait(context.Background()); err != nil {
+		t.Fatalf("UnsubscribeWait failed: %v", err)
+	}
+
+	snapshot := metrics.Snapshot()
+	if snapshot.Published != 4 || snapshot.Unrouted != 1 || snapshot.Subscriptions != 0 {
+		t.Errorf("snapshot = %+v, want 4 published, 1 unrouted, 0 subscriptions", snapshot)
+	}
+	p, ok := snapshot.Patterns["orders.>"]
+	if !ok {
+		t.Fatalf("no metrics for pattern orders.>: %+v", snapshot)
+	}
+	if p.Enqueued != 3 || p.Delivered != 3 || p.Failed != 1 || len(p.Dropped) != 0 {
+		t.Errorf("pattern metrics = %+v, want 3 enqueued, 3 delivered, 1 failed", p)
+	}
+	if p.Latency.Count != 3 || p.Latency.Max <= 0 || p.Latency.Mean() > p.Latency.Max {
+		t.Errorf("latency = %+v, want 3 observations", p.Latency)
+	}
+}
+
+func TestMetricsDropped(t *testing.T) {
+	metrics := NewMetrics()
+	sp := NewSubPub(WithObserver(metrics))
+
+	_, sub := subscribeCollector(t, sp, "test", WithFilter(func(string, interface{}) bool { return false }))
+	defer sub.Unsubscribe()
+
+	sp.Publish("test", "msg")
+	sp.Publish("test", "msg")
+
+	p := metrics.Snapshot().Patterns["test"]
+	if p.Subscriptions != 1 || p.Dropped[DropFiltered] != 2 || p.Enqueued != 0 {
+		t.Errorf("pattern metrics = %+v, want 1 subscription, 2 filtered", p)
+	}
+}
+
+func TestMetricsIgnoreRequestInboxes(t *testing.T) {
+	metrics := NewMetrics()
+	sp := NewSubPub(WithObserver(metrics))
+
+	sub, err := sp.SubscribeRequests("echo", func(msg interface{}, respond Responder) {
+		respond(msg)
+	})
+	if err != nil {
+		t.Fatalf("SubscribeRequests failed: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	for i := 0; i < 100; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		_, err := sp.Request(ctx, "echo", i)
+		cancel()
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+	}
+
+	snapshot := metrics.Snapshot()
+	if len(snapshot.Patterns) != 1 || snapshot.Subscriptions != 1 {
+		t.Errorf("snapshot = %+v, want only the echo subscription", snapshot)
+	}
+	if p := snapshot.Patterns["echo"]; p.Enqueued != 100 {
+		t.Errorf("echo metrics = %+v, want 100 enqueued", p)
+	}
+}
+
+func TestHistogramQuantile(t *testing.T) {
+	h := newHistogram([]time.Duration{time.Millisecond, 10 * time.Millisecond, 100 * time.Millisecond})
+	for i := 0; i < 90; i++ {
+		h.observe(500 * time.Microsecond)
+	}
+	for i := 0; i < 9; i++ {
+		h.observe(5 * time.Millisecond)
+	}
+	h.observe(time.Second)
+
+	snapshot := h.snapshot()
+	if snapshot.Count != 100 || snapshot.Max != time.Second {
+		t.Fatalf("snapshot = %+v, want 100 observations with max 1s", snapshot)
+	}
+	tests := []struct {
+		q    float64
+		want time.Duration
+	}{
+		{0, time.Millisecond},
+		{0.5, time.Millisecond},
+		{0.9, time.Millisecond},
+		{0.95, 10 * time.Millisecond},
+		{0.99, 10 * time.Millisecond},
+		{1, time.Second},
+	}
+	for _, tt := range tests {
+		if got := snapshot.Quantile(tt.q); got != tt.want {
+			t.Errorf("Quantile(%v) = %v, want %v", tt.q, got, tt.want)
+		}
+	}
+}
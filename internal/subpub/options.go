@@ -0,0 +1,171 @@
+package subpub
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultQueueSize — ёмкость очереди подписчика по умолчанию.
+const DefaultQueueSize = 1024
+
+// Option настраивает шину при создании через NewSubPub.
+type Option func(*options)
+
+type options struct {
+	queueSize   int
+	dropHandler DropHandler
+	logger      *logrus.Logger
+	errorHook   ErrorHook
+
+	publishInterceptors  []PublishInterceptor
+	deliveryInterceptors []DeliveryInterceptor
+
+	retain int
+
+	observers []Observer
+	transport Transport
+}
+
+func defaultOptions() options {
+	return options{
+		queueSize: DefaultQueueSize,
+		logger:    logrus.StandardLogger(),
+	}
+}
+
+// WithQueueSize задаёт ёмкость очереди каждого подписчика.
+// Неположительные значения игнорируются.
+func WithQueueSize(size int) Option {
+	return func(o *options) {
+		if size > 0 {
+			o.queueSize = size
+		}
+	}
+}
+
+// WithDropHandler задаёт обработчик, которому сообщается о каждом
+// отброшенном при переполнении сообщении.
+func WithDropHandler(h DropHandler) Option {
+	return func(o *options) {
+		o.dropHandler = h
+	}
+}
+
+// WithLogger задаёт логгер, в который по умолчанию пишутся ошибки доставки.
+func WithLogger(logger *logrus.Logger) Option {
+	return func(o *options) {
+		if logger != nil {
+			o.logger = logger
+		}
+	}
+}
+
+// WithErrorHook заменяет запись ошибок доставки в лог собственным обработчиком.
+func WithErrorHook(hook ErrorHook) Option {
+	return func(o *options) {
+		o.errorHook = hook
+	}
+}
+
+// WithRetainedMessages включает хранение последних n сообщений каждого
+// субъекта: новые подписчики получают их до начала живого потока.
+// Подписки в группах очередей сохранённые сообщения не получают.
+func WithRetainedMessages(n int) Option {
+	return func(o *options) {
+		o.retain = n
+	}
+}
+
+// SubscribeOption настраивает отдельную подписку.
+type SubscribeOption func(*subscribeOptions)
+
+type subscribeOptions struct {
+	group        string
+	queueSize    int
+	policy       OverflowPolicy
+	blockTimeout time.Duration
+	maxPanics    int
+	filters      []Filter
+	onDrop       DropHandler
+
+	rate        float64
+	burst       int
+	maxInFlight int
+
+	retry       bool
+	retryPolicy RetryPolicy
+	onFailure   FailureHandler
+	deadLetter  string
+	// retryOptions — задан хотя бы один параметр повторной обработки
+	retryOptions bool
+
+	// batch не nil у пакетной подписки
+	batch *batchOptions
+	// out не nil у канальной подписки
+	out chan interface{}
+	// unobserved — служебная подписка, о которой не сообщается наблюдателям
+	unobserved bool
+}
+
+func (o options) subscribeDefaults() subscribeOptions {
+	return subscribeOptions{
+		queueSize:    o.queueSize,
+		policy:       DropNewest,
+		blockTimeout: DefaultBlockTimeout,
+		maxInFlight:  1,
+		retryPolicy:  defaultRetryPolicy(),
+	}
+}
+
+// WithQueueGroup включает подписку в группу очередей: каждое сообщение
+// получает только один участник группы.
+func WithQueueGroup(group string) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.group = group
+	}
+}
+
+// WithBufferSize переопределяет ёмкость очереди подписки.
+// Неположительные значения игнорируются.
+func WithBufferSize(size int) SubscribeOption {
+	return func(o *subscribeOptions) {
+		if size > 0 {
+			o.queueSize = size
+		}
+	}
+}
+
+// WithOverflowPolicy задаёт поведение подписки при переполнении очереди.
+func WithOverflowPolicy(policy OverflowPolicy) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.policy = policy
+	}
+}
+
+// WithBlockTimeout задаёт, сколько издатель ждёт места в очереди при политике Block.
+// Неположительные значения игнорируются.
+func WithBlockTimeout(timeout time.Duration) SubscribeOption {
+	return func(o *subscribeOptions) {
+		if timeout > 0 {
+			o.blockTimeout = timeout
+		}
+	}
+}
+
+// WithSubscriptionDropHandler задаёт обработчик, которому сообщается
+// о каждом сообщении, отброшенном из-за переполнения очереди этой подписки.
+// Вызывается вместе с обработчиком шины (WithDropHandler).
+func WithSubscriptionDropHandler(h DropHandler) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.onDrop = h
+	}
+}
+
+// WithMaxConsecutivePanics автоматически отписывает обработчик после n паник подряд.
+// Значение 0 (по умолчанию) отключает автоматическую отписку.
+func WithMaxConsecutivePanics(n int) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.maxPanics = n
+	}
+}
@@ -0,0 +1,58 @@
+package subpub
+
+import (
+	"fmt"
+	"time"
+)
+
+// OverflowPolicy определяет, что происходит с новым сообщением,
+// когда очередь подписчика заполнена.
+type OverflowPolicy int
+
+const (
+	// DropNewest отбрасывает публикуемое сообщение.
+	DropNewest OverflowPolicy = iota
+	// DropOldest вытесняет самое старое сообщение из очереди.
+	DropOldest
+	// Block блокирует издателя до освобождения места, но не дольше таймаута,
+	// после чего сообщение отбрасывается.
+	Block
+	// Disconnect принудительно отписывает медленного подписчика.
+	Disconnect
+)
+
+// DefaultBlockTimeout — время ожидания места в очереди для политики Block по умолчанию.
+const DefaultBlockTimeout = 100 * time.Millisecond
+
+var overflowPolicyNames = map[OverflowPolicy]string{
+	DropNewest: "drop_newest",
+	DropOldest: "drop_oldest",
+	Block:      "block",
+	Disconnect: "disconnect",
+}
+
+func (p OverflowPolicy) String() string {
+	if name, ok := overflowPolicyNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("OverflowPolicy(%d)", int(p))
+}
+
+// ParseOverflowPolicy разбирает имя политики: drop_newest, drop_oldest, block или disconnect.
+// Пустая строка соответствует политике по умолчанию DropNewest.
+func ParseOverflowPolicy(name string) (OverflowPolicy, error) {
+	if name == "" {
+		return DropNewest, nil
+	}
+	for p, n := range overflowPolicyNames {
+		if n == name {
+			return p, nil
+		}
+	}
+	return DropNewest, fmt.Errorf("subpub: unknown overflow policy %q", name)
+}
+
+// DropHandler получает уведомление о каждом сообщении, отброшенном из-за
+// переполнения очереди подписки subject. При политике Disconnect вызывается
+// и для сообщений, отброшенных при принудительной отписке.
+type DropHandler func(subject string, msg interface{}, policy OverflowPolicy)
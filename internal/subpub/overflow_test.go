@@ -0,0 +1,244 @@
+package subpub
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingSubscriber подписывается на subject и задерживает обработку
+// первого сообщения до вызова release.
+type blockingSubscriber struct {
+	sub      Subscription
+	started  chan struct{}
+	release  chan struct{}
+	received chan interface{}
+}
+
+func subscribeBlocking(t *testing.T, sp SubPub, subject string, opts ...SubscribeOption) *blockingSubscriber {
+	t.Helper()
+
+	b := &blockingSubscriber{
+		started:  make(chan struct{}),
+		release:  make(chan struct{}),
+		received: make(chan interface{}, 100),
+	}
+	var once sync.Once
+	sub, err := sp.Subscribe(subject, func(msg interface{}) {
+		once.Do(func() {
+			close(b.started)
+			<-b.release
+		})
+		b.received <- msg
+	}, opts...)
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	b.sub = sub
+	return b
+}
+
+func (b *blockingSubscriber) expect(t *testing.T, want ...interface{}) {
+	t.Helper()
+
+	for _, w := range want {
+		select {
+		case got := <-b.received:
+			if got != w {
+				t.Errorf("got %v, want %v", got, w)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for message %v", w)
+		}
+	}
+
+	select {
+	case got := <-b.received:
+		t.Errorf("received unexpected message %v", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestOverflowDropOldest(t *testing.T) {
+	var mu sync.Mutex
+	var dropped []interface{}
+	sp := NewSubPub(WithDropHandler(func(subject string, msg interface{}, policy OverflowPolicy) {
+		mu.Lock()
+		dropped = append(dropped, msg)
+		mu.Unlock()
+		if policy != DropOldest {
+			t.Errorf("policy = %v, want %v", policy, DropOldest)
+		}
+	}))
+
+	b := subscribeBlocking(t, sp, "test", WithBufferSize(2), WithOverflowPolicy(DropOldest))
+	defer b.sub.Unsubscribe()
+
+	sp.Publish("test", 0)
+	<-b.started
+	for i := 1; i <= 4; i++ {
+		sp.Publish("test", i)
+	}
+	close(b.release)
+
+	b.expect(t, 0, 3, 4)
+	if n := b.sub.Stats().Dropped; n != 2 {
+		t.Errorf("Stats().Dropped = %d, want 2", n)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(dropped) != 2 || dropped[0] != 1 || dropped[1] != 2 {
+		t.Errorf("dropped %v, want [1 2]", dropped)
+	}
+}
+
+func TestOverflowDropNewestCounts(t *testing.T) {
+	sp := NewSubPub()
+
+	b := subscribeBlocking(t, sp, "test", WithBufferSize(1))
+	defer b.sub.Unsubscribe()
+
+	sp.Publish("test", 0)
+	<-b.started
+	for i := 1; i <= 3; i++ {
+		sp.Publish("test", i)
+	}
+	close(b.release)
+
+	b.expect(t, 0, 1)
+	if n := b.sub.Stats().Dropped; n != 2 {
+		t.Errorf("Stats().Dropped = %d, want 2", n)
+	}
+}
+
+func TestSubscriptionDropHandler(t *testing.T) {
+	var busDrops, subDrops []interface{}
+	var mu sync.Mutex
+	sp := NewSubPub(WithDropHandler(func(_ string, msg interface{}, _ OverflowPolicy) {
+		mu.Lock()
+		busDrops = append(busDrops, msg)
+		mu.Unlock()
+	}))
+
+	b := subscribeBlocking(t, sp, "test", WithBufferSize(1), WithSubscriptionDropHandler(func(subject string, msg interface{}, policy OverflowPolicy) {
+		if subject != "test" || policy != DropNewest {
+			t.Errorf("got subject %q policy %v, want test %v", subject, policy, DropNewest)
+		}
+		mu.Lock()
+		subDrops = append(subDrops, msg)
+		mu.Unlock()
+	}))
+	defer b.sub.Unsubscribe()
+	other := subscribeBlocking(t, sp, "test")
+	defer other.sub.Unsubscribe()
+	close(other.release)
+
+	sp.Publish("test", 0)
+	<-b.started
+	for i := 1; i <= 3; i++ {
+		sp.Publish("test", i)
+	}
+	close(b.release)
+	b.expect(t, 0, 1)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(subDrops) != 2 || subDrops[0] != 2 || subDrops[1] != 3 {
+		t.Errorf("subscription drop handler got %v, want [2 3]", subDrops)
+	}
+	if len(busDrops) != 2 {
+		t.Errorf("bus drop handler got %v, want 2 drops", busDrops)
+	}
+}
+
+func TestOverflowBlockWaitsForSpace(t *testing.T) {
+	sp := NewSubPub()
+
+	b := subscribeBlocking(t, sp, "test", WithBufferSize(1), WithOverflowPolicy(Block), WithBlockTimeout(time.Second))
+	defer b.sub.Unsubscribe()
+
+	sp.Publish("test", 0)
+	<-b.started
+	sp.Publish("test", 1)
+
+	published := make(chan struct{})
+	go func() {
+		sp.Publish("test", 2)
+		close(published)
+	}()
+
+	select {
+	case <-published:
+		t.Fatal("Publish did not block on a full queue")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(b.release)
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("Publish stayed blocked after space was freed")
+	}
+
+	b.expect(t, 0, 1, 2)
+	if n := b.sub.Stats().Dropped; n != 0 {
+		t.Errorf("Stats().Dropped = %d, want 0", n)
+	}
+}
+
+func TestOverflowBlockTimeout(t *testing.T) {
+	sp := NewSubPub()
+
+	b := subscribeBlocking(t, sp, "test", WithBufferSize(1), WithOverflowPolicy(Block), WithBlockTimeout(50*time.Millisecond))
+	defer b.sub.Unsubscribe()
+
+	sp.Publish("test", 0)
+	<-b.started
+	sp.Publish("test", 1)
+
+	start := time.Now()
+	sp.Publish("test", 2)
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Publish returned after %v, want at least the block timeout", elapsed)
+	}
+	close(b.release)
+
+	b.expect(t, 0, 1)
+	if n := b.sub.Stats().Dropped; n != 1 {
+		t.Errorf("Stats().Dropped = %d, want 1", n)
+	}
+}
+
+func TestOverflowDisconnect(t *testing.T) {
+	sp := NewSubPub()
+
+	b := subscribeBlocking(t, sp, "test", WithBufferSize(1), WithOverflowPolicy(Disconnect))
+
+	sp.Publish("test", 0)
+	<-b.started
+	sp.Publish("test", 1)
+	sp.Publish("test", 2)
+	sp.Publish("test", 3)
+	close(b.release)
+
+	// Сообщение 0 уже обрабатывалось; ожидавшее 1 и вызвавшее отключение 2 отброшены
+	b.expect(t, 0)
+	if n := b.sub.Stats().Dropped; n != 2 {
+		t.Errorf("Stats().Dropped = %d, want 2", n)
+	}
+}
+
+func TestParseOverflowPolicy(t *testing.T) {
+	for _, p := range []OverflowPolicy{DropNewest, DropOldest, Block, Disconnect} {
+		got, err := ParseOverflowPolicy(p.String())
+		if err != nil || got != p {
+			t.Errorf("ParseOverflowPolicy(%q) = %v, %v; want %v", p.String(), got, err, p)
+		}
+	}
+	if got, err := ParseOverflowPolicy(""); err != nil || got != DropNewest {
+		t.Errorf("ParseOverflowPolicy(\"\") = %v, %v; want %v", got, err, DropNewest)
+	}
+	if _, err := ParseOverflowPolicy("unknown"); err == nil {
+		t.Error("ParseOverflowPolicy(\"unknown\") returned no error")
+	}
+}
@@ -0,0 +1,105 @@
+package subpub
+
+import (
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrorHook получает ошибки, возникшие при доставке сообщений: паники
+// обработчиков (*PanicError) и автоматические отписки после серии паник.
+type ErrorHook func(err error)
+
+// PanicError описывает панику, перехваченную в обработчике подписки.
+type PanicError struct {
+	// Subject — субъект (шаблон) подписки, обработчик которой паниковал
+	Subject string
+	// Msg — сообщение, при обработке которого произошла паника
+	Msg interface{}
+	// Value — значение, переданное в panic
+	Value interface{}
+	// Stack — стек горутины в момент паники
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("subpub: handler for %q panicked: %v", e.Subject, e.Value)
+}
+
+// logErrors — обработчик ошибок по умолчанию, пишущий их в logger.
+func logErrors(logger *logrus.Logger) ErrorHook {
+	return func(err error) {
+		entry := logger.WithError(err)
+		if p, ok := err.(*PanicError); ok {
+			entry = entry.WithFields(logrus.Fields{
+				"subject": p.Subject,
+				"panic":   p.Value,
+				"stack":   string(p.Stack),
+			})
+		}
+		entry.Error("ошибка при доставке сообщения")
+	}
+}
+
+// invoke доставляет сообщение обработчику. В режиме подтверждений и
+// с повтором обработки неудачно обработанное сообщение доставляется повторно.
+// Вызывается только из горутины доставки.
+func (s *subscription) invoke(env *envelope) {
+	if s.opts.retry {
+		s.invokeRetry(env)
+		return
+	}
+
+	// Паника уже сообщена в recovered, остаются ошибки перехватчиков доставки
+	if err := s.call(env); err != nil {
+		if _, ok := err.(*PanicError); !ok {
+			s.bus.reportError(err)
+		}
+	}
+}
+
+// call вызывает обработчик, не позволяя его панике выйти за пределы подписки.
+// Перехваченная паника возвращается как *PanicError.
+func (s *subscription) call(env *envelope) (err error) {
+	s.delivered.Add(1)
+	s.lastDelivery.Store(time.Now().UnixNano())
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = s.recovered(env, r, debug.Stack())
+		}
+		s.observeDeliver(env, err)
+	}()
+
+	err = s.deliver(env)
+	s.consecutivePanics.Store(0)
+	return err
+}
+
+func (s *subscription) recovered(env *envelope, value interface{}, stack []byte) error {
+	s.panicked.Add(1)
+	panics := s.consecutivePanics.Add(1)
+
+	err := &PanicError{
+		Subject: s.subject,
+		Msg:     env.msg,
+		Value:   value,
+		Stack:   stack,
+	}
+	s.bus.reportError(err)
+
+	if limit := s.opts.maxPanics; limit > 0 && panics >= int64(limit) {
+		s.Unsubscribe()
+		s.bus.reportError(fmt.Errorf("subpub: subscription %q unsubscribed after %d consecutive panics: %w",
+			s.subject, panics, ErrTooManyPanics))
+	}
+	return err
+}
+
+func (sp *subPub) reportError(err error) {
+	if sp.opts.errorHook != nil {
+		sp.opts.errorHook(err)
+	}
+}
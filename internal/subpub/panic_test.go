@@ -0,0 +1,157 @@
+package subpub
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestPanicIsolation(t *testing.T) {
+	errs := make(chan error, 10)
+	sp := NewSubPub(WithErrorHook(func(err error) {
+		errs <- err
+	}))
+
+	received := make(chan interface{}, 10)
+	sub, err := sp.Subscribe("test", func(msg interface{}) {
+		if msg == "boom" {
+			panic("handler exploded")
+		}
+		received <- msg
+	})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	for _, msg := range []string{"before", "boom", "after"} {
+		if err := sp.Publish("test", msg); err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+	}
+
+	for _, want := range []string{"before", "after"} {
+		select {
+		case got := <-received:
+			if got != want {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for %q", want)
+		}
+	}
+
+	select {
+	case err := <-errs:
+		var p *PanicError
+		if !errors.As(err, &p) {
+			t.Fatalf("error hook got %T, want *PanicError", err)
+		}
+		if p.Value != "handler exploded" || p.Msg != "boom" || p.Subject != "test" {
+			t.Errorf("unexpected panic error: %+v", p)
+		}
+		if len(p.Stack) == 0 {
+			t.Error("panic error has no stack")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("error hook was not called")
+	}
+
+	if n := sub.Stats().Panicked; n != 1 {
+		t.Errorf("Stats().Panicked = %d, want 1", n)
+	}
+}
+
+func TestPanicLoggedByDefault(t *testing.T) {
+	var buf syncBuffer
+	logger := logrus.New()
+	logger.SetOutput(&buf)
+
+	sp := NewSubPub(WithLogger(logger))
+
+	done := make(chan struct{})
+	sub, err := sp.Subscribe("test", func(msg interface{}) {
+		defer close(done)
+		panic("logged panic")
+	})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	sp.Publish("test", "msg")
+	<-done
+	time.Sleep(20 * time.Millisecond)
+
+	if out := buf.String(); !strings.Contains(out, "logged panic") {
+		t.Errorf("log output %q does not mention the panic", out)
+	}
+}
+
+func TestAutoUnsubscribeAfterConsecutivePanics(t *testing.T) {
+	errs := make(chan error, 10)
+	sp := NewSubPub(WithErrorHook(func(err error) {
+		errs <- err
+	}))
+
+	calls := make(chan interface{}, 10)
+	sub, err := sp.Subscribe("test", func(msg interface{}) {
+		calls <- msg
+		if msg != "ok" {
+			panic(msg)
+		}
+	}, WithMaxConsecutivePanics(2))
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	// Успешный вызов между паниками сбрасывает счётчик
+	for _, msg := range []string{"bad", "ok", "bad", "bad", "after"} {
+		sp.Publish("test", msg)
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	var got []interface{}
+	for len(calls) > 0 {
+		got = append(got, <-calls)
+	}
+	if len(got) != 4 {
+		t.Fatalf("handler called with %v, want 4 calls before auto-unsubscribe", got)
+	}
+	if n := sub.Stats().Panicked; n != 3 {
+		t.Errorf("Stats().Panicked = %d, want 3", n)
+	}
+
+	var tooMany bool
+	for len(errs) > 0 {
+		if errors.Is(<-errs, ErrTooManyPanics) {
+			tooMany = true
+		}
+	}
+	if !tooMany {
+		t.Error("auto-unsubscribe was not reported to the error hook")
+	}
+}
+
+// syncBuffer — потокобезопасный bytes.Buffer для перехвата вывода логгера.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
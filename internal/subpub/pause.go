@@ -0,0 +1,32 @@
+package subpub
+
+// Pause приостанавливает доставку: новые сообщения копятся в очереди
+// подписки, а при её переполнении действует политика переполнения.
+// Уже выполняющийся обработчик не прерывается. Приостановленная подписка
+// не доставляет сообщения и при закрытии шины, поэтому Close ждёт Resume
+// не дольше, чем позволяет его контекст.
+func (s *subscription) Pause() {
+	s.paused.Store(true)
+}
+
+// Resume возобновляет доставку накопленных сообщений в порядке их публикации.
+func (s *subscription) Resume() {
+	if s.paused.CompareAndSwap(true, false) {
+		notify(s.resumed)
+		// Сообщения, накопленные за время паузы, могли не оставить сигнала в ready
+		notify(s.ready)
+	}
+}
+
+// waitResumed ждёт возобновления приостановленной подписки. Возвращает
+// false, если подписка была остановлена.
+func (s *subscription) waitResumed() bool {
+	for s.paused.Load() {
+		select {
+		case <-s.resumed:
+		case <-s.done:
+			return false
+		}
+	}
+	return true
+}
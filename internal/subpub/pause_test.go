@@ -0,0 +1,137 @@
+package subpub
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPauseResume(t *testing.T) {
+	sp := NewSubPub()
+
+	c, sub := subscribeCollector(t, sp, "test")
+	defer sub.Unsubscribe()
+
+	sp.Publish("test", 0)
+	c.waitFor(t, 1)
+
+	sub.Pause()
+	for i := 1; i <= 3; i++ {
+		sp.Publish("test", i)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	stats := sub.Stats()
+	if !stats.Paused || stats.Delivered != 1 || stats.Pending != 3 {
+		t.Errorf("stats while paused = %+v, want paused, 1 delivered, 3 pending", stats)
+	}
+
+	sub.Resume()
+	got := c.waitFor(t, 4)
+	for i, msg := range got {
+		if msg != i {
+			t.Errorf("message %d = %v, want %d", i, msg, i)
+		}
+	}
+	if sub.Stats().Paused {
+		t.Error("Paused = true after Resume")
+	}
+}
+
+func TestPauseAppliesOverflowPolicy(t *testing.T) {
+	sp := NewSubPub()
+
+	c, sub := subscribeCollector(t, sp, "test", WithBufferSize(2), WithOverflowPolicy(DropOldest))
+	defer sub.Unsubscribe()
+
+	sub.Pause()
+	for i := 0; i < 5; i++ {
+		sp.Publish("test", i)
+	}
+	if stats := sub.Stats(); stats.Pending != 2 || stats.Dropped != 3 {
+		t.Errorf("stats = %+v, want 2 pending, 3 dropped", stats)
+	}
+
+	sub.Resume()
+	got := c.waitFor(t, 2)
+	if got[0] != 3 || got[1] != 4 {
+		t.Errorf("got %v, want [3 4]", got)
+	}
+}
+
+func TestPausedBatchSubscription(t *testing.T) {
+	sp := NewSubPub()
+
+	c, sub := subscribeBatches(t, sp, "test", 2, 10*time.Millisecond)
+	defer sub.Unsubscribe()
+
+	sub.Pause()
+	for i := 0; i < 3; i++ {
+		sp.Publish("test", i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if got := c.get(); len(got) != 0 {
+		t.Fatalf("received %v while paused", got)
+	}
+
+	sub.Resume()
+	got := c.waitFor(t, 2)
+	if len(got[0]) != 2 || len(got[1]) != 1 {
+		t.Errorf("batches = %v, want [[0 1] [2]]", got)
+	}
+}
+
+func TestQueueGroupPrefersActiveMembers(t *testing.T) {
+	sp := NewSubPub()
+
+	paused, pausedSub := subscribeCollectorQueue(t, sp, "test", "workers")
+	defer pausedSub.Unsubscribe()
+	active, activeSub := subscribeCollectorQueue(t, sp, "test", "workers")
+	defer activeSub.Unsubscribe()
+
+	pausedSub.Pause()
+	for i := 0; i < 10; i++ {
+		sp.Publish("test", i)
+	}
+
+	active.waitFor(t, 10)
+	paused.mu.Lock()
+	defer paused.mu.Unlock()
+	if n := len(paused.msgs); n != 0 {
+		t.Errorf("paused member received %d messages, want 0", n)
+	}
+}
+
+func TestCloseWaitsForPausedSubscription(t *testing.T) {
+	sp := NewSubPub()
+
+	c, sub := subscribeCollector(t, sp, "test")
+	sub.Pause()
+	sp.Publish("test", "msg")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := sp.Close(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Close: got %v, want %v", err, context.DeadlineExceeded)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.msgs) != 0 {
+		t.Errorf("paused subscription received %v", c.msgs)
+	}
+}
+
+func subscribeCollectorQueue(t *testing.T, sp SubPub, subject, group string) (*collector, Subscription) {
+	t.Helper()
+
+	c := &collector{}
+	sub, err := sp.SubscribeQueue(subject, group, func(msg interface{}) {
+		c.mu.Lock()
+		c.msgs = append(c.msgs, msg)
+		c.mu.Unlock()
+	})
+	if err != nil {
+		t.Fatalf("SubscribeQueue failed: %v", err)
+	}
+	return c, sub
+}
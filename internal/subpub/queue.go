@@ -0,0 +1,73 @@
+package subpub
+
+// initialQueueCapacity — начальный размер буфера очереди. Буфер растёт
+// по мере заполнения, поэтому память под полную ёмкость выделяется только
+// подпискам, которые действительно отстают.
+const initialQueueCapacity = 16
+
+// queue — кольцевой буфер ограниченной ёмкости.
+// Не потокобезопасен, синхронизация лежит на владельце.
+type queue[T any] struct {
+	items []T
+	head  int
+	size  int
+	limit int
+}
+
+func newQueue[T any](limit int) *queue[T] {
+	return &queue[T]{
+		items: make([]T, min(limit, initialQueueCapacity)),
+		limit: limit,
+	}
+}
+
+func (q *queue[T]) len() int {
+	return q.size
+}
+
+func (q *queue[T]) full() bool {
+	return q.size == q.limit
+}
+
+// push добавляет элемент в хвост. Возвращает false, если очередь заполнена.
+func (q *queue[T]) push(item T) bool {
+	if q.full() {
+		return false
+	}
+	if q.size == len(q.items) {
+		q.grow()
+	}
+	q.items[(q.head+q.size)%len(q.items)] = item
+	q.size++
+	return true
+}
+
+// grow удваивает буфер, не превышая ёмкости очереди.
+func (q *queue[T]) grow() {
+	items := make([]T, min(2*len(q.items), q.limit))
+	n := copy(items, q.items[q.head:])
+	copy(items[n:], q.items[:q.head])
+	q.items = items
+	q.head = 0
+}
+
+// pop извлекает элемент из головы очереди.
+func (q *queue[T]) pop() (T, bool) {
+	var zero T
+	if q.size == 0 {
+		return zero, false
+	}
+	item := q.items[q.head]
+	q.items[q.head] = zero
+	q.head = (q.head + 1) % len(q.items)
+	q.size--
+	return item, true
+}
+
+// appendTo добавляет элементы очереди к dst от головы к хвосту, не извлекая их.
+func (q *queue[T]) appendTo(dst []T) []T {
+	for i := 0; i < q.size; i++ {
+		dst = append(dst, q.items[(q.head+i)%len(q.items)])
+	}
+	return dst
+}
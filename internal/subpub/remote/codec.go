@@ -0,0 +1,130 @@
+package remote
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Codec переводит сообщения шины в данные событий сервиса PubSub и обратно.
+// Тип, возвращённый Encode, передаётся в метаданных события и при получении
+// передаётся в Decode вместе с данными. Данные события не могут быть пустыми.
+type Codec interface {
+	Encode(msg interface{}) (data, kind string, err error)
+	Decode(data, kind string) (interface{}, error)
+}
+
+// Типы сообщений DefaultCodec, кроме числовых и bool, которые называются
+// по имени типа Go.
+const (
+	kindString = "string"
+	kindBytes  = "bytes"
+	kindNil    = "nil"
+	kindJSON   = "json"
+	// kindEmpty — пустая строка или пустой срез байт: тип хранится в данных
+	kindEmpty = "empty"
+)
+
+// DefaultCodec передаёт строки как есть, срезы байт — в base64, nil, bool
+// и числа встроенных типов — в текстовом виде с сохранением типа, а остальные
+// сообщения — в JSON. JSON декодируется в типы пакета encoding/json, поэтому
+// структуры приходят подписчикам как map[string]interface{}. События без
+// типа, например опубликованные другими клиентами сервиса, декодируются
+// как строки.
+type DefaultCodec struct{}
+
+func (DefaultCodec) Encode(msg interface{}) (string, string, error) {
+	var data, kind string
+	switch v := msg.(type) {
+	case nil:
+		return "null", kindNil, nil
+	case string:
+		data, kind = v, kindString
+	case []byte:
+		data, kind = base64.StdEncoding.EncodeToString(v), kindBytes
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		data, kind = fmt.Sprint(v), fmt.Sprintf("%T", v)
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return "", "", err
+		}
+		data, kind = string(b), kindJSON
+	}
+
+	if data == "" {
+		return kind, kindEmpty, nil
+	}
+	return data, kind, nil
+}
+
+func (DefaultCodec) Decode(data, kind string) (interface{}, error) {
+	switch kind {
+	case "", kindString:
+		return data, nil
+	case kindEmpty:
+		if data == kindBytes {
+			return []byte{}, nil
+		}
+		return "", nil
+	case kindNil:
+		return nil, nil
+	case kindBytes:
+		return base64.StdEncoding.DecodeString(data)
+	case kindJSON:
+		var v interface{}
+		if err := json.Unmarshal([]byte(data), &v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	case "bool":
+		return strconv.ParseBool(data)
+	case "int":
+		return parseInt[int](data, 0)
+	case "int8":
+		return parseInt[int8](data, 8)
+	case "int16":
+		return parseInt[int16](data, 16)
+	case "int32":
+		return parseInt[int32](data, 32)
+	case "int64":
+		return parseInt[int64](data, 64)
+	case "uint":
+		return parseUint[uint](data, 0)
+	case "uint8":
+		return parseUint[uint8](data, 8)
+	case "uint16":
+		return parseUint[uint16](data, 16)
+	case "uint32":
+		return parseUint[uint32](data, 32)
+	case "uint64":
+		return parseUint[uint64](data, 64)
+	case "float32":
+		v, err := strconv.ParseFloat(data, 32)
+		if err != nil {
+			return nil, err
+		}
+		return float32(v), nil
+	case "float64":
+		return strconv.ParseFloat(data, 64)
+	default:
+		return nil, fmt.Errorf("remote: unknown message type %q", kind)
+	}
+}
+
+func parseInt[T int | int8 | int16 | int32 | int64](data string, bits int) (interface{}, error) {
+	v, err := strconv.ParseInt(data, 10, bits)
+	if err != nil {
+		return nil, err
+	}
+	return T(v), nil
+}
+
+func parseUint[T uint | uint8 | uint16 | uint32 | uint64](data string, bits int) (interface{}, error) {
+	v, err := strconv.ParseUint(data, 10, bits)
+	if err != nil {
+		return nil, err
+	}
+	return T(v), nil
+}
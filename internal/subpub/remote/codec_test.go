@@ -0,0 +1,118 @@
+package remote
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestDefaultCodecRoundTrip(t *testing.T) {
+	tests := []interface{}{
+		"order",
+		"",
+		[]byte{0, 1, 0xfe, 0xff},
+		[]byte{},
+		nil,
+		true,
+		false,
+		int(-42),
+		int8(math.MinInt8),
+		int16(math.MaxInt16),
+		int32(math.MinInt32),
+		int64(math.MaxInt64),
+		uint(42),
+		uint8(math.MaxUint8),
+		uint16(math.MaxUint16),
+		uint32(math.MaxUint32),
+		uint64(math.MaxUint64),
+		float32(1.5),
+		float64(-0.1),
+		math.Inf(1),
+	}
+
+	var codec DefaultCodec
+	for _, msg := range tests {
+		data, kind, err := codec.Encode(msg)
+		if err != nil {
+			t.Errorf("Encode(%#v) failed: %v", msg, err)
+			continue
+		}
+		// Сервис PubSub не принимает события с пустыми данными
+		if data == "" {
+			t.Errorf("Encode(%#v) returned empty data", msg)
+		}
+
+		got, err := codec.Decode(data, kind)
+		if err != nil {
+			t.Errorf("Decode(%q, %q) failed: %v", data, kind, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, msg) {
+			t.Errorf("round trip of %#v: got %#v", msg, got)
+		}
+	}
+}
+
+func TestDefaultCodecJSON(t *testing.T) {
+	type order struct {
+		ID    int      `json:"id"`
+		Items []string `json:"items"`
+	}
+
+	tests := []struct {
+		msg  interface{}
+		want interface{}
+	}{
+		{order{ID: 1, Items: []string{"a"}}, map[string]interface{}{"id": float64(1), "items": []interface{}{"a"}}},
+		{&order{ID: 2}, map[string]interface{}{"id": float64(2), "items": nil}},
+		{[]int{1, 2}, []interface{}{float64(1), float64(2)}},
+		{map[string]string{"region": "eu"}, map[string]interface{}{"region": "eu"}},
+	}
+
+	var codec DefaultCodec
+	for _, tt := range tests {
+		data, kind, err := codec.Encode(tt.msg)
+		if err != nil {
+			t.Errorf("Encode(%#v) failed: %v", tt.msg, err)
+			continue
+		}
+		if kind != kindJSON {
+			t.Errorf("Encode(%#v): kind = %q, want %q", tt.msg, kind, kindJSON)
+		}
+
+		got, err := codec.Decode(data, kind)
+		if err != nil {
+			t.Errorf("Decode(%q) failed: %v", data, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("round trip of %#v: got %#v, want %#v", tt.msg, got, tt.want)
+		}
+	}
+
+	if _, _, err := codec.Encode(make(chan int)); err == nil {
+		t.Error("Encode of a channel succeeded")
+	}
+}
+
+func TestDefaultCodecDecode(t *testing.T) {
+	var codec DefaultCodec
+
+	// События других клиентов сервиса приходят без типа
+	if got, err := codec.Decode("order", ""); err != nil || got != "order" {
+		t.Errorf(`Decode("order", "") = %#v, %v; want "order"`, got, err)
+	}
+
+	for _, tt := range []struct{ data, kind string }{
+		{"300", "int8"},
+		{"-1", "uint"},
+		{"yes", "bool"},
+		{"%%%", kindBytes},
+		{"{", kindJSON},
+		{"1", "complex128"},
+	} {
+		if got, err := codec.Decode(tt.data, tt.kind); err == nil {
+			t.Errorf("Decode(%q, %q) = %#v, want error", tt.data, tt.kind, got)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package remote
+
+import (
+	"time"
+
+	"awesomeProject3/internal/subpub"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultTimeout — время по умолчанию на публикацию и на установку подписки.
+	DefaultTimeout = 10 * time.Second
+	// DefaultFlushTimeout — сколько по умолчанию Close ждёт сообщений,
+	// опубликованных через транспорт до закрытия.
+	DefaultFlushTimeout = 5 * time.Second
+)
+
+// Option настраивает транспорт при создании через NewTransport.
+type Option func(*options)
+
+type options struct {
+	codec        Codec
+	timeout      time.Duration
+	flushTimeout time.Duration
+	backoff      subpub.Backoff
+	logger       *logrus.Logger
+}
+
+func defaultOptions() options {
+	return options{
+		codec:        DefaultCodec{},
+		timeout:      DefaultTimeout,
+		flushTimeout: DefaultFlushTimeout,
+		backoff:      subpub.ExponentialBackoff(100*time.Millisecond, 5*time.Second, 0.2),
+		logger:       logrus.StandardLogger(),
+	}
+}
+
+// WithCodec задаёт кодек, которым сообщения переводятся в данные события и обратно.
+func WithCodec(codec Codec) Option {
+	return func(o *options) {
+		if codec != nil {
+			o.codec = codec
+		}
+	}
+}
+
+// WithTimeout задаёт, сколько ждать ответа сервера на публикацию и установки
+// подписки, в том числе пока соединение с сервером недоступно.
+// Неположительные значения игнорируются.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		if timeout > 0 {
+			o.timeout = timeout
+		}
+	}
+}
+
+// WithFlushTimeout ограничивает ожидание в Close сообщений, опубликованных
+// через транспорт до закрытия: сервер может их отбросить, и тогда они не придут.
+// Неположительные значения игнорируются.
+func WithFlushTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		if timeout > 0 {
+			o.flushTimeout = timeout
+		}
+	}
+}
+
+// WithReconnectBackoff задаёт задержки перед попытками повторно открыть
+// прерванную подписку; номер попытки сбрасывается после успешного открытия.
+// nil игнорируется.
+func WithReconnectBackoff(backoff subpub.Backoff) Option {
+	return func(o *options) {
+		if backoff != nil {
+			o.backoff = backoff
+		}
+	}
+}
+
+// WithLogger задаёт логгер для сообщений о переподключениях и ошибках декодирования.
+func WithLogger(logger *logrus.Logger) Option {
+	return func(o *options) {
+		if logger != nil {
+			o.logger = logger
+		}
+	}
+}
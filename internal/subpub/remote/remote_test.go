@@ -0,0 +1,270 @@
+package remote_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"awesomeProject3/internal/domain/repository"
+	pubsubgrpc "awesomeProject3/internal/pubsub/delivery/grpc"
+	"awesomeProject3/internal/subpub"
+	"awesomeProject3/internal/subpub/remote"
+	"awesomeProject3/internal/subpub/subpubtest"
+	"awesomeProject3/internal/usecase/publish"
+	"awesomeProject3/internal/usecase/subscribe"
+	"awesomeProject3/pkg/config"
+	"awesomeProject3/pkg/proto"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// testServer — сервис PubSub, работающий в процессе теста.
+type testServer struct {
+	server *grpc.Server
+	repo   *repository.InMemoryRepository
+}
+
+func startTestServer(t *testing.T, lis *bufconn.Listener) *testServer {
+	t.Helper()
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	logger.SetLevel(logrus.ErrorLevel)
+
+	repo := repository.NewInMemoryRepository(subpub.WithLogger(logger), subpub.WithQueueSize(1<<16))
+	handler := pubsubgrpc.NewHandler(logger, publish.New(repo, logger), subscribe.New(repo, logger), config.PubSubConfig{
+		MessageBufferSize: 1 << 16,
+		OverflowPolicy:    "block",
+	})
+	server := grpc.NewServer()
+	proto.RegisterPubSubServer(server, handler)
+	go server.Serve(lis)
+
+	return &testServer{server: server, repo: repo}
+}
+
+func (s *testServer) stop() {
+	s.server.Stop()
+	s.repo.Close(context.Background())
+}
+
+// dialer позволяет подменять listener при перезапуске сервера.
+type dialer struct {
+	lis chan *bufconn.Listener
+}
+
+func newDialer(lis *bufconn.Listener) *dialer {
+	d := &dialer{lis: make(chan *bufconn.Listener, 1)}
+	d.lis <- lis
+	return d
+}
+
+func (d *dialer) set(lis *bufconn.Listener) {
+	<-d.lis
+	d.lis <- lis
+}
+
+func (d *dialer) dial(ctx context.Context, _ string) (net.Conn, error) {
+	lis := <-d.lis
+	d.lis <- lis
+	return lis.DialContext(ctx)
+}
+
+func dialTestServer(t *testing.T, d *dialer) *grpc.ClientConn {
+	t.Helper()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(d.dial),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithConnectParams(grpc.ConnectParams{
+			Backoff:           backoff.Config{BaseDelay: 10 * time.Millisecond, Multiplier: 1.6, MaxDelay: 100 * time.Millisecond},
+			MinConnectTimeout: time.Second,
+		}),
+	)
+	if err != nil {
+		t.Fatalf("failed to dial test server: %v", err)
+	}
+	return conn
+}
+
+// newRemoteBus создаёт шину, подключённую к собственному серверу в процессе теста.
+func newRemoteBus(t *testing.T, opts ...subpub.Option) subpub.SubPub {
+	lis := bufconn.Listen(1 << 20)
+	server := startTestServer(t, lis)
+	conn := dialTestServer(t, newDialer(lis))
+
+	sp := remote.NewSubPub(conn, opts...)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		sp.Close(ctx)
+		conn.Close()
+		server.stop()
+	})
+	return sp
+}
+
+func TestRemoteBehaviour(t *testing.T) {
+	// Каждая публикация — вызов gRPC, который под детектором гонок
+	// выполняется в несколько раз медленнее. Нагрузочные тесты отправляют
+	// столько же сообщений, что и для локальной шины, но ждут дольше
+	var opts []subpubtest.SuiteOption
+	if raceEnabled {
+		opts = append(opts, subpubtest.WithTimeFactor(4))
+	}
+	subpubtest.RunBehaviourSuite(t, newRemoteBus, opts...)
+}
+
+func TestRemoteWildcardSubject(t *testing.T) {
+	sp := newRemoteBus(t)
+
+	received := make(chan string, 1)
+	sub, err := sp.Subscribe("orders.*", func(msg interface{}) {
+		received <- msg.(string)
+	}, subpub.WithFilter(func(subject string, _ interface{}) bool {
+		return subject == "orders.created"
+	}))
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	if err := sp.Publish("orders.updated", "skipped"); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if err := sp.Publish("orders.created", "order"); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	select {
+	case got := <-received:
+		if got != "order" {
+			t.Errorf("got %q, want %q", got, "order")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for message")
+	}
+}
+
+func TestRemoteMessageHeaders(t *testing.T) {
+	sp := newRemoteBus(t)
+
+	received := make(chan *subpub.Message, 1)
+	sub, err := sp.SubscribeMsg("orders.*", func(msg *subpub.Message) {
+		received <- msg
+	})
+	if err != nil {
+		t.Fatalf("SubscribeMsg failed: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	before := time.Now()
+	err = sp.PublishMsg(&subpub.Message{
+		Subject: "orders.created",
+		Headers: map[string]string{"region": "eu"},
+		Payload: 42,
+	})
+	if err != nil {
+		t.Fatalf("PublishMsg failed: %v", err)
+	}
+
+	select {
+	case msg := <-received:
+		if msg.Subject != "orders.created" || msg.Payload != 42 {
+			t.Errorf("got %s %v, want orders.created 42", msg.Subject, msg.Payload)
+		}
+		if len(msg.Headers) != 1 || msg.Headers["region"] != "eu" {
+			t.Errorf("Headers: got %v, want region=eu", msg.Headers)
+		}
+		if msg.Timestamp.Before(before) || msg.Timestamp.After(time.Now()) {
+			t.Errorf("Timestamp %v is outside of the publish interval", msg.Timestamp)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for message")
+	}
+}
+
+func TestRemoteRequest(t *testing.T) {
+	sp := newRemoteBus(t)
+
+	sub, err := sp.SubscribeRequests("echo", func(msg interface{}, respond subpub.Responder) {
+		respond(msg.(int) * 2)
+	})
+	if err != nil {
+		t.Fatalf("SubscribeRequests failed: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	reply, err := sp.Request(ctx, "echo", 21)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if reply != 42 {
+		t.Errorf("got %v, want 42", reply)
+	}
+}
+
+func TestRemoteReconnect(t *testing.T) {
+	lis := bufconn.Listen(1 << 20)
+	server := startTestServer(t, lis)
+	d := newDialer(lis)
+	conn := dialTestServer(t, d)
+	defer conn.Close()
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	sp := subpub.NewSubPub(subpub.WithTransport(remote.NewTransport(conn,
+		remote.WithLogger(logger),
+		remote.WithReconnectBackoff(subpub.FixedBackoff(10*time.Millisecond)),
+	)))
+	defer sp.Close(context.Background())
+
+	received := make(chan interface{}, 10)
+	sub, err := sp.Subscribe("test", func(msg interface{}) {
+		received <- msg
+	})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	if err := sp.Publish("test", "before restart"); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	select {
+	case <-received:
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for message before restart")
+	}
+
+	server.stop()
+	lis = bufconn.Listen(1 << 20)
+	d.set(lis)
+	server = startTestServer(t, lis)
+	defer server.stop()
+
+	// Подписка восстанавливается в фоне: публикуем, пока сообщение не дойдёт
+	deadline := time.After(5 * time.Second)
+	for {
+		if err := sp.Publish("test", "after restart"); err != nil {
+			t.Fatalf("Publish after restart failed: %v", err)
+		}
+		select {
+		case got := <-received:
+			if got != "after restart" {
+				t.Fatalf("got %v, want %q", got, "after restart")
+			}
+			return
+		case <-time.After(20 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("subscription was not restored after server restart")
+		}
+	}
+}
@@ -0,0 +1,428 @@
+package remote
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"awesomeProject3/internal/subpub"
+	"awesomeProject3/pkg/proto"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Ключи метаданных событий, которыми транспорт сопровождает публикации.
+// Остальные метаданные — заголовки сообщения.
+const (
+	metaPrefix = "subpub-"
+	metaType   = metaPrefix + "type"
+	metaReply  = metaPrefix + "reply"
+	metaOrigin = metaPrefix + "origin"
+	metaSeq    = metaPrefix + "seq"
+	metaTime   = metaPrefix + "time"
+)
+
+// NewSubPub создаёт шину, которая публикует сообщения и получает их через
+// сервис PubSub, доступный по conn. Чтобы настроить сам транспорт, шину
+// создают через subpub.NewSubPub с subpub.WithTransport(NewTransport(conn, ...)).
+func NewSubPub(conn grpc.ClientConnInterface, opts ...subpub.Option) subpub.SubPub {
+	opts = append(opts[:len(opts):len(opts)], subpub.WithTransport(NewTransport(conn)))
+	return subpub.NewSubPub(opts...)
+}
+
+// Transport реализует subpub.Transport поверх методов Publish и Subscribe
+// сервиса PubSub. Сообщения переводятся в данные событий кодеком, а заголовки,
+// время публикации и субъект ответа передаются в метаданных события.
+// Заголовки с префиксом "subpub-" зарезервированы и не передаются.
+//
+// Прерванные подписки открываются заново с задержкой WithReconnectBackoff.
+// Сообщения, опубликованные, пока подписка не была открыта, ей не доставляются.
+type Transport struct {
+	client proto.PubSubClient
+	opts   options
+	// origin отличает публикации этого транспорта от публикаций других клиентов
+	origin string
+
+	// published — число публикаций, начатых через транспорт
+	published atomic.Uint64
+	// closed изменяется под mu, а читается без блокировки
+	closed  atomic.Bool
+	mu      sync.Mutex
+	streams map[*stream]struct{}
+	wg      sync.WaitGroup
+}
+
+// NewTransport создаёт транспорт поверх соединения с сервисом PubSub.
+// Соединением по-прежнему управляет вызывающий: транспорт его не закрывает.
+func NewTransport(conn grpc.ClientConnInterface, opts ...Option) *Transport {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return &Transport{
+		client:  proto.NewPubSubClient(conn),
+		opts:    o,
+		origin:  newOrigin(),
+		streams: make(map[*stream]struct{}),
+	}
+}
+
+func newOrigin() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Publish отправляет сообщение сервису и возвращает управление, когда
+// сервис поставил его в очереди своих подписчиков. Пока соединение
+// недоступно, публикация ждёт его и повторяется после обрыва, но не дольше
+// WithTimeout. Если сервис успел принять сообщение до обрыва, подписчики
+// могут получить его дважды.
+func (t *Transport) Publish(msg *subpub.Message, reply string) error {
+	if t.closed.Load() {
+		return subpub.ErrClosed
+	}
+
+	subject := msg.Subject
+	data, kind, err := t.opts.codec.Encode(msg.Payload)
+	if err != nil {
+		return fmt.Errorf("remote: failed to encode message for %q: %w", subject, err)
+	}
+	seq := t.published.Add(1)
+	metadata := make(map[string]string, len(msg.Headers)+5)
+	for key, value := range msg.Headers {
+		if !strings.HasPrefix(key, metaPrefix) {
+			metadata[key] = value
+		}
+	}
+	metadata[metaType] = kind
+	metadata[metaOrigin] = t.origin
+	metadata[metaSeq] = strconv.FormatUint(seq, 10)
+	metadata[metaTime] = strconv.FormatInt(msg.Timestamp.UnixNano(), 10)
+	if reply != "" {
+		metadata[metaReply] = reply
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), t.opts.timeout)
+	defer cancel()
+	req := &proto.PublishRequest{Key: subject, Data: data, Metadata: metadata}
+	for attempt := 1; ; attempt++ {
+		_, err = t.client.Publish(ctx, req, grpc.WaitForReady(true))
+		if status.Code(err) != codes.Unavailable || !t.wait(ctx, attempt) {
+			break
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("remote: failed to publish to %q: %w", subject, err)
+	}
+
+	t.mu.Lock()
+	for s := range t.streams {
+		s.expect(seq, subject)
+	}
+	t.mu.Unlock()
+	return nil
+}
+
+// wait выдерживает задержку перед повторной попыткой номер attempt+1.
+// Возвращает false, если ctx истёк раньше.
+func (t *Transport) wait(ctx context.Context, attempt int) bool {
+	timer := time.NewTimer(t.opts.backoff(attempt))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Subscribe открывает подписку сервиса на субъект или шаблон и возвращает
+// управление, когда сервис её зарегистрировал. Пока соединение недоступно,
+// открытие ждёт его, но не дольше WithTimeout.
+func (t *Transport) Subscribe(pattern string, deliver subpub.TransportHandler) (func(), error) {
+	if t.closed.Load() {
+		return nil, subpub.ErrClosed
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &stream{
+		transport: t,
+		pattern:   pattern,
+		deliver:   deliver,
+		ctx:       ctx,
+		cancel:    cancel,
+		progress:  make(chan struct{}, 1),
+	}
+	events, stop, err := s.open()
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("remote: failed to subscribe to %q: %w", pattern, err)
+	}
+
+	t.mu.Lock()
+	if t.closed.Load() {
+		t.mu.Unlock()
+		stop()
+		cancel()
+		return nil, subpub.ErrClosed
+	}
+	t.streams[s] = struct{}{}
+	t.wg.Add(1)
+	t.mu.Unlock()
+
+	go s.run(events, stop)
+	return s.stop, nil
+}
+
+// Close прекращает публикации, ждёт, пока открытые подписки получат
+// сообщения, опубликованные через транспорт до закрытия, и отменяет их.
+// Ожидание ограничено ctx и WithFlushTimeout.
+func (t *Transport) Close(ctx context.Context) error {
+	t.mu.Lock()
+	if t.closed.Load() {
+		t.mu.Unlock()
+		return nil
+	}
+	t.closed.Store(true)
+	streams := make([]*stream, 0, len(t.streams))
+	for s := range t.streams {
+		streams = append(streams, s)
+	}
+	t.mu.Unlock()
+
+	flushCtx, cancel := context.WithTimeout(ctx, t.opts.flushTimeout)
+	defer cancel()
+
+	var err error
+	for _, s := range streams {
+		if err = s.flush(flushCtx); err != nil {
+			break
+		}
+	}
+	for _, s := range streams {
+		s.stop()
+	}
+	t.wg.Wait()
+
+	switch {
+	case err == nil:
+		return nil
+	case ctx.Err() != nil:
+		return ctx.Err()
+	default:
+		return fmt.Errorf("remote: messages published before close were not received within %v", t.opts.flushTimeout)
+	}
+}
+
+// stream — подписка сервиса на шаблон. Поток событий открывается заново,
+// если прерывается до отмены подписки.
+type stream struct {
+	transport *Transport
+	pattern   string
+	deliver   subpub.TransportHandler
+	ctx       context.Context
+	cancel    context.CancelFunc
+
+	mu sync.Mutex
+	// since — номер последней публикации транспорта, начатой до открытия
+	// текущего потока: более ранние публикации поток мог не получить
+	since uint64
+	// expected и received — число публикаций транспорта после since,
+	// подходящих под шаблон, и число уже полученных из них
+	expected uint64
+	received uint64
+	// progress получает сигнал при изменении received
+	progress chan struct{}
+}
+
+// open открывает поток событий и ждёт, пока сервис зарегистрирует подписку.
+// Возвращает поток и функцию, освобождающую его.
+func (s *stream) open() (proto.PubSub_SubscribeClient, context.CancelFunc, error) {
+	ctx, cancel := context.WithCancel(s.ctx)
+	timer := time.AfterFunc(s.transport.opts.timeout, cancel)
+
+	events, err := s.transport.client.Subscribe(ctx, &proto.SubscribeRequest{Key: s.pattern}, grpc.WaitForReady(true))
+	if err == nil {
+		var header map[string][]string
+		header, err = events.Header()
+		if err == nil && header == nil {
+			// Поток завершён без заголовка, причина — в его статусе
+			if _, err = events.Recv(); err == nil || err == io.EOF {
+				err = errors.New("stream closed before the subscription was registered")
+			}
+		}
+	}
+	if !timer.Stop() {
+		err = fmt.Errorf("subscription was not registered within %v", s.transport.opts.timeout)
+	}
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+
+	s.mu.Lock()
+	s.since = s.transport.published.Load()
+	s.expected, s.received = 0, 0
+	s.mu.Unlock()
+	s.notify()
+	return events, cancel, nil
+}
+
+// run получает события, пока подписка не отменена, и открывает поток
+// заново, если он прерывается.
+func (s *stream) run(events proto.PubSub_SubscribeClient, stop context.CancelFunc) {
+	defer s.transport.wg.Done()
+
+	log := s.transport.opts.logger.WithField("key", s.pattern)
+	for {
+		err := s.receive(events)
+		stop()
+		if s.ctx.Err() != nil {
+			return
+		}
+		log.WithError(err).Warn("подписка на сервер прервана, переподключение")
+
+		// Пока поток закрыт, публикации транспорта не ожидаются
+		s.mu.Lock()
+		s.since = math.MaxUint64
+		s.expected, s.received = 0, 0
+		s.mu.Unlock()
+		s.notify()
+
+		for attempt := 1; ; attempt++ {
+			if !s.transport.wait(s.ctx, attempt) {
+				return
+			}
+
+			events, stop, err = s.open()
+			if err == nil {
+				break
+			}
+			if s.ctx.Err() != nil {
+				return
+			}
+			log.WithError(err).WithField("attempt", attempt).Debug("не удалось переподключить подписку")
+		}
+		log.Info("подписка на сервер восстановлена")
+	}
+}
+
+// receive передаёт полученные события шине до ошибки потока.
+func (s *stream) receive(events proto.PubSub_SubscribeClient) error {
+	for {
+		event, err := events.Recv()
+		if err != nil {
+			return err
+		}
+
+		metadata := event.GetMetadata()
+		payload, err := s.transport.opts.codec.Decode(event.GetData(), metadata[metaType])
+		if err != nil {
+			s.transport.opts.logger.WithError(err).WithFields(logrus.Fields{
+				"key":     s.pattern,
+				"subject": event.GetKey(),
+			}).Error("не удалось декодировать событие")
+		} else {
+			s.deliver(message(event, payload), metadata[metaReply])
+		}
+
+		if metadata[metaOrigin] == s.transport.origin {
+			seq, _ := strconv.ParseUint(metadata[metaSeq], 10, 64)
+			s.receivedOwn(seq)
+		}
+	}
+}
+
+// message создаёт сообщение шины из события сервиса.
+func message(event *proto.Event, payload interface{}) *subpub.Message {
+	msg := &subpub.Message{Subject: event.GetKey(), Payload: payload}
+	for key, value := range event.GetMetadata() {
+		switch {
+		case key == metaTime:
+			if ns, err := strconv.ParseInt(value, 10, 64); err == nil {
+				msg.Timestamp = time.Unix(0, ns)
+			}
+		case strings.HasPrefix(key, metaPrefix):
+		default:
+			if msg.Headers == nil {
+				msg.Headers = make(map[string]string)
+			}
+			msg.Headers[key] = value
+		}
+	}
+	return msg
+}
+
+// expect учитывает успешную публикацию транспорта, которую должен получить поток.
+func (s *stream) expect(seq uint64, subject string) {
+	if !subpub.MatchSubject(s.pattern, subject) {
+		return
+	}
+	s.mu.Lock()
+	if seq > s.since {
+		s.expected++
+	}
+	s.mu.Unlock()
+}
+
+// receivedOwn учитывает полученную и уже доставленную шине публикацию транспорта.
+func (s *stream) receivedOwn(seq uint64) {
+	s.mu.Lock()
+	if seq > s.since {
+		s.received++
+	}
+	s.mu.Unlock()
+	s.notify()
+}
+
+func (s *stream) notify() {
+	select {
+	case s.progress <- struct{}{}:
+	default:
+	}
+}
+
+// flush ждёт, пока поток получит ожидаемые публикации транспорта.
+func (s *stream) flush(ctx context.Context) error {
+	for {
+		s.mu.Lock()
+		done := s.received >= s.expected
+		s.mu.Unlock()
+		if done {
+			return nil
+		}
+
+		select {
+		case <-s.progress:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// stop отменяет подписку. Не ждёт завершения получения событий, поэтому
+// может вызываться из deliver.
+func (s *stream) stop() {
+	s.cancel()
+
+	t := s.transport
+	t.mu.Lock()
+	delete(t.streams, s)
+	t.mu.Unlock()
+}
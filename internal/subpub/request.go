@@ -0,0 +1,121 @@
+package subpub
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+)
+
+// inboxRoot — корневой токен субъектов, на которые приходят ответы на запросы.
+const inboxRoot = "_INBOX"
+
+// Responder отправляет ответ на запрос. Для сообщений, опубликованных
+// через Publish, ответить некуда, и он возвращает ErrNoReplySubject.
+type Responder func(reply interface{}) error
+
+// RequestHandler обрабатывает запрос и может ответить на него через respond.
+// Ответ необязателен и может быть отправлен несколько раз.
+type RequestHandler func(msg interface{}, respond Responder)
+
+// SubscribeRequests подписывает обработчик запросов на субъект или шаблон.
+// Обычные сообщения, опубликованные через Publish, он также получает.
+func (sp *subPub) SubscribeRequests(subject string, cb RequestHandler, opts ...SubscribeOption) (Subscription, error) {
+	return subscribed(sp.subscribe(subject, func(_ context.Context, env *envelope) error {
+		cb(env.msg, sp.responder(env.reply))
+		return nil
+	}, opts))
+}
+
+func (sp *subPub) responder(reply string) Responder {
+	return func(msg interface{}) error {
+		if reply == "" {
+			return ErrNoReplySubject
+		}
+		_, err := sp.publish(&envelope{subject: reply, msg: msg})
+		return err
+	}
+}
+
+// Request публикует запрос и ждёт первого ответа до отмены ctx.
+// Если на субъект никто не подписан, сразу возвращает ErrNoResponders.
+func (sp *subPub) Request(ctx context.Context, subject string, msg interface{}) (interface{}, error) {
+	replies, err := sp.request(ctx, subject, msg, 1)
+	if err != nil {
+		return nil, err
+	}
+	return replies[0], nil
+}
+
+// RequestMany публикует запрос и собирает ответы, пока не истечёт ctx или
+// не будет получено max ответов (max <= 0 — без ограничения). Истечение ctx
+// является штатным завершением сбора: ошибка возвращается, только если
+// не пришло ни одного ответа.
+func (sp *subPub) RequestMany(ctx context.Context, subject string, msg interface{}, max int) ([]interface{}, error) {
+	return sp.request(ctx, subject, msg, max)
+}
+
+func (sp *subPub) request(ctx context.Context, subject string, msg interface{}, max int) ([]interface{}, error) {
+	if err := validateSubject(subject); err != nil {
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	incoming := make(chan interface{})
+	inbox, err := sp.subscribe(sp.newInbox(), func(_ context.Context, env *envelope) error {
+		select {
+		case incoming <- env.msg:
+		case <-done:
+		}
+		return nil
+	}, []SubscribeOption{unobserved()})
+	if err != nil {
+		return nil, err
+	}
+	defer inbox.Unsubscribe()
+
+	n, err := sp.publish(&envelope{subject: subject, msg: msg, reply: inbox.subject})
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, ErrNoResponders
+	}
+
+	var replies []interface{}
+	for max <= 0 || len(replies) < max {
+		select {
+		case reply := <-incoming:
+			replies = append(replies, reply)
+		case <-ctx.Done():
+			if len(replies) == 0 {
+				return nil, ctx.Err()
+			}
+			return replies, nil
+		}
+	}
+	return replies, nil
+}
+
+// unobserved исключает подписку на ответы из уведомлений наблюдателей:
+// у каждого запроса свой субъект ответа, и метрики по ним бесполезны.
+func unobserved() SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.unobserved = true
+	}
+}
+
+// newInbox возвращает уникальный субъект для ответов на один запрос.
+func (sp *subPub) newInbox() string {
+	return inboxRoot + tokenSeparator + sp.inboxPrefix + tokenSeparator + strconv.FormatUint(sp.inboxSeq.Add(1), 10)
+}
+
+func newInboxPrefix() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "local"
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,186 @@
+package subpub
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequestReply(t *testing.T) {
+	sp := NewSubPub()
+
+	sub, err := sp.SubscribeRequests("math.double", func(msg interface{}, respond Responder) {
+		if err := respond(msg.(int) * 2); err != nil {
+			t.Errorf("respond failed: %v", err)
+		}
+	})
+	if err != nil {
+		t.Fatalf("SubscribeRequests failed: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	for i := 0; i < 10; i++ {
+		reply, err := sp.Request(ctx, "math.double", i)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		if reply != i*2 {
+			t.Errorf("got %v, want %d", reply, i*2)
+		}
+	}
+}
+
+func TestRequestNoResponders(t *testing.T) {
+	sp := NewSubPub()
+
+	_, err := sp.Request(context.Background(), "nobody.home", "ping")
+	if err != ErrNoResponders {
+		t.Errorf("Request: got %v, want %v", err, ErrNoResponders)
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	sp := NewSubPub()
+
+	// Подписчик есть, но не отвечает
+	sub, err := sp.Subscribe("silent", func(msg interface{}) {})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := sp.Request(ctx, "silent", "ping"); err != context.DeadlineExceeded {
+		t.Errorf("Request: got %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestRequestManyCollectsUntilDeadline(t *testing.T) {
+	const responderCount = 3
+	sp := NewSubPub()
+
+	for i := 0; i < responderCount; i++ {
+		name := fmt.Sprintf("node-%d", i)
+		sub, err := sp.SubscribeRequests("discovery", func(msg interface{}, respond Responder) {
+			respond(name)
+		})
+		if err != nil {
+			t.Fatalf("SubscribeRequests failed: %v", err)
+		}
+		defer sub.Unsubscribe()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	replies, err := sp.RequestMany(ctx, "discovery", "who is there", 0)
+	if err != nil {
+		t.Fatalf("RequestMany failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("RequestMany returned after %v, before the deadline", elapsed)
+	}
+	if len(replies) != responderCount {
+		t.Errorf("got %d replies, want %d", len(replies), responderCount)
+	}
+}
+
+func TestRequestManyStopsAtMax(t *testing.T) {
+	sp := NewSubPub()
+
+	for i := 0; i < 3; i++ {
+		sub, err := sp.SubscribeRequests("discovery", func(msg interface{}, respond Responder) {
+			respond("here")
+		})
+		if err != nil {
+			t.Fatalf("SubscribeRequests failed: %v", err)
+		}
+		defer sub.Unsubscribe()
+	}
+
+	replies, err := sp.RequestMany(context.Background(), "discovery", "who is there", 2)
+	if err != nil {
+		t.Fatalf("RequestMany failed: %v", err)
+	}
+	if len(replies) != 2 {
+		t.Errorf("got %d replies, want 2", len(replies))
+	}
+}
+
+func TestRequestWithQueueGroupResponders(t *testing.T) {
+	sp := NewSubPub()
+
+	for i := 0; i < 3; i++ {
+		sub, err := sp.SubscribeRequests("work", func(msg interface{}, respond Responder) {
+			respond("done")
+		}, WithQueueGroup("workers"))
+		if err != nil {
+			t.Fatalf("SubscribeRequests failed: %v", err)
+		}
+		defer sub.Unsubscribe()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	replies, err := sp.RequestMany(ctx, "work", "job", 0)
+	if err != nil {
+		t.Fatalf("RequestMany failed: %v", err)
+	}
+	if len(replies) != 1 {
+		t.Errorf("got %d replies from a queue group, want 1", len(replies))
+	}
+}
+
+func TestRespondWithoutReplySubject(t *testing.T) {
+	sp := NewSubPub()
+
+	errs := make(chan error, 1)
+	sub, err := sp.SubscribeRequests("events", func(msg interface{}, respond Responder) {
+		errs <- respond("ack")
+	})
+	if err != nil {
+		t.Fatalf("SubscribeRequests failed: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	if err := sp.Publish("events", "fire and forget"); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	select {
+	case err := <-errs:
+		if err != ErrNoReplySubject {
+			t.Errorf("respond: got %v, want %v", err, ErrNoReplySubject)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for handler")
+	}
+}
+
+func TestInboxSubjectsAreUnique(t *testing.T) {
+	sp := NewSubPub().(*subPub)
+
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		inbox := sp.newInbox()
+		if !strings.HasPrefix(inbox, inboxRoot+tokenSeparator) {
+			t.Fatalf("inbox %q has no %s prefix", inbox, inboxRoot)
+		}
+		if err := validateSubject(inbox); err != nil {
+			t.Fatalf("inbox %q is not a valid subject: %v", inbox, err)
+		}
+		if seen[inbox] {
+			t.Fatalf("inbox %q generated twice", inbox)
+		}
+		seen[inbox] = true
+	}
+}
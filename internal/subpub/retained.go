@@ -0,0 +1,110 @@
+package subpub
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// retainedStore хранит последние опубликованные сообщения каждого субъекта
+// для воспроизведения новым подписчикам.
+type retainedStore struct {
+	limit int
+
+	mu       sync.Mutex
+	subjects map[string]*queue[*envelope]
+}
+
+func newRetainedStore(limit int) *retainedStore {
+	return &retainedStore{
+		limit:    limit,
+		subjects: make(map[string]*queue[*envelope]),
+	}
+}
+
+// retainable сообщает, нужно ли сохранять сообщение. Запросы и ответы
+// на них не сохраняются: воспроизводить их новым подписчикам бессмысленно.
+func retainable(env *envelope) bool {
+	return env.reply == "" && !strings.HasPrefix(env.subject, inboxRoot+tokenSeparator)
+}
+
+func (r *retainedStore) add(env *envelope) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	q, ok := r.subjects[env.subject]
+	if !ok {
+		q = newQueue[*envelope](r.limit)
+		r.subjects[env.subject] = q
+	}
+	if q.full() {
+		q.pop()
+	}
+	q.push(env)
+}
+
+// has сообщает, есть ли сохранённые сообщения субъекта. Допускает nil.
+func (r *retainedStore) has(subject string) bool {
+	if r == nil {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, ok := r.subjects[subject]
+	return ok
+}
+
+// match возвращает сохранённые сообщения субъектов, подходящих под шаблон,
+// в порядке их публикации.
+func (r *retainedStore) match(tokens []string) []*envelope {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var envs []*envelope
+	if !hasWildcard(tokens) {
+		if q, ok := r.subjects[strings.Join(tokens, tokenSeparator)]; ok {
+			envs = q.appendTo(envs)
+		}
+		return envs
+	}
+
+	for subject, q := range r.subjects {
+		if matchTokens(tokens, splitSubject(subject)) {
+			envs = q.appendTo(envs)
+		}
+	}
+	sort.Slice(envs, func(i, j int) bool {
+		return envs[i].ordinal < envs[j].ordinal
+	})
+	return envs
+}
+
+// clear удаляет сохранённые сообщения субъектов, подходящих под шаблон.
+func (r *retainedStore) clear(tokens []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !hasWildcard(tokens) {
+		delete(r.subjects, strings.Join(tokens, tokenSeparator))
+		return
+	}
+	for subject := range r.subjects {
+		if matchTokens(tokens, splitSubject(subject)) {
+			delete(r.subjects, subject)
+		}
+	}
+}
+
+// ClearRetained удаляет сохранённые сообщения субъекта. Допускается шаблон:
+// тогда очищаются все подходящие под него субъекты.
+func (sp *subPub) ClearRetained(subject string) error {
+	tokens, err := validatePattern(subject)
+	if err != nil {
+		return err
+	}
+	if sp.retained != nil {
+		sp.retained.clear(tokens)
+	}
+	return nil
+}
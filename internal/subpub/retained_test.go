@@ -0,0 +1,173 @@
+package subpub
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// collector подписывается на subject и складывает полученные сообщения в срез.
+type collector struct {
+	mu   sync.Mutex
+	msgs []interface{}
+}
+
+func subscribeCollector(t *testing.T, sp SubPub, subject string, opts ...SubscribeOption) (*collector, Subscription) {
+	t.Helper()
+
+	c := &collector{}
+	sub, err := sp.Subscribe(subject, func(msg interface{}) {
+		c.mu.Lock()
+		c.msgs = append(c.msgs, msg)
+		c.mu.Unlock()
+	}, opts...)
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	return c, sub
+}
+
+func (c *collector) waitFor(t *testing.T, n int) []interface{} {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		c.mu.Lock()
+		got := append([]interface{}(nil), c.msgs...)
+		c.mu.Unlock()
+		if len(got) >= n {
+			return got
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("received %d messages, want %d: %v", len(got), n, got)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRetainedLatestMessage(t *testing.T) {
+	sp := NewSubPub(WithRetainedMessages(1))
+
+	for i := 0; i < 3; i++ {
+		sp.Publish("config.db", i)
+	}
+
+	c, sub := subscribeCollector(t, sp, "config.db")
+	defer sub.Unsubscribe()
+
+	sp.Publish("config.db", 3)
+
+	got := c.waitFor(t, 2)
+	if len(got) != 2 || got[0] != 2 || got[1] != 3 {
+		t.Errorf("got %v, want [2 3]", got)
+	}
+}
+
+func TestRetainedLastN(t *testing.T) {
+	sp := NewSubPub(WithRetainedMessages(3))
+
+	for i := 0; i < 5; i++ {
+		sp.Publish("events.a", fmt.Sprintf("a%d", i))
+		sp.Publish("events.b", fmt.Sprintf("b%d", i))
+	}
+	sp.Publish("other", "x")
+
+	c, sub := subscribeCollector(t, sp, "events.*")
+	defer sub.Unsubscribe()
+
+	// Воспроизведение упорядочено по времени публикации по всем подходящим субъектам
+	got := c.waitFor(t, 6)
+	want := []interface{}{"a2", "b2", "a3", "b3", "a4", "b4"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i, w := range want {
+		if got[i] != w {
+			t.Errorf("got[%d] = %v, want %v", i, got[i], w)
+		}
+	}
+}
+
+func TestRetainedHandOffWithoutGapsOrDuplicates(t *testing.T) {
+	const messageCount = 5000
+	sp := NewSubPub(WithRetainedMessages(messageCount), WithQueueSize(messageCount*2))
+
+	published := make(chan struct{})
+	go func() {
+		defer close(published)
+		for i := 0; i < messageCount; i++ {
+			sp.Publish("stream", i)
+		}
+	}()
+
+	// Подписываемся посреди публикации
+	time.Sleep(time.Millisecond)
+	c, sub := subscribeCollector(t, sp, "stream")
+	defer sub.Unsubscribe()
+	<-published
+
+	got := c.waitFor(t, messageCount)
+	if len(got) != messageCount {
+		t.Fatalf("received %d messages, want %d", len(got), messageCount)
+	}
+	for i, msg := range got {
+		if msg != i {
+			t.Fatalf("message %d: got %v", i, msg)
+		}
+	}
+}
+
+func TestClearRetained(t *testing.T) {
+	sp := NewSubPub(WithRetainedMessages(1))
+
+	sp.Publish("config.db", "db")
+	sp.Publish("config.cache", "cache")
+	sp.Publish("state", "state")
+
+	if err := sp.ClearRetained("config.*"); err != nil {
+		t.Fatalf("ClearRetained failed: %v", err)
+	}
+	if err := sp.ClearRetained("a..b"); err != ErrInvalidSubject {
+		t.Errorf("ClearRetained with invalid subject: got %v, want %v", err, ErrInvalidSubject)
+	}
+
+	c, sub := subscribeCollector(t, sp, ">")
+	defer sub.Unsubscribe()
+
+	got := c.waitFor(t, 1)
+	time.Sleep(20 * time.Millisecond)
+	if len(got) != 1 || got[0] != "state" {
+		t.Errorf("got %v, want [state]", got)
+	}
+}
+
+func TestRetainedNotReplayedToQueueGroups(t *testing.T) {
+	sp := NewSubPub(WithRetainedMessages(1))
+	sp.Publish("jobs", "old")
+
+	c, sub := subscribeCollector(t, sp, "jobs", WithQueueGroup("workers"))
+	defer sub.Unsubscribe()
+
+	sp.Publish("jobs", "new")
+	got := c.waitFor(t, 1)
+	time.Sleep(20 * time.Millisecond)
+	if len(got) != 1 || got[0] != "new" {
+		t.Errorf("got %v, want [new]", got)
+	}
+}
+
+func TestRetentionDisabledByDefault(t *testing.T) {
+	sp := NewSubPub()
+	sp.Publish("config.db", "db")
+
+	c, sub := subscribeCollector(t, sp, "config.db")
+	defer sub.Unsubscribe()
+
+	time.Sleep(20 * time.Millisecond)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.msgs) != 0 {
+		t.Errorf("received %v without retention enabled", c.msgs)
+	}
+}
@@ -0,0 +1,206 @@
+package subpub
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"runtime/debug"
+	"time"
+)
+
+// RetryHandler обрабатывает сообщение и возвращает ошибку, если обработка
+// не удалась. Ненулевая ошибка приводит к повторной обработке того же
+// сообщения согласно политике повторов подписки.
+type RetryHandler func(ctx context.Context, msg interface{}) error
+
+func (cb RetryHandler) deliver(ctx context.Context, env *envelope) error {
+	return cb(ctx, env.msg)
+}
+
+// Backoff возвращает задержку перед повторной попыткой номер attempt+1
+// после неудачной попытки номер attempt (нумерация с 1).
+type Backoff func(attempt int) time.Duration
+
+// FixedBackoff возвращает политику задержек с постоянной задержкой d.
+func FixedBackoff(d time.Duration) Backoff {
+	return func(int) time.Duration {
+		return d
+	}
+}
+
+// ExponentialBackoff возвращает политику задержек, удваивающую задержку
+// начиная с initial, но не более max. jitter из диапазона [0, 1] задаёт
+// долю задержки, которая случайно вычитается из неё, чтобы повторы разных
+// подписчиков не совпадали по времени.
+func ExponentialBackoff(initial, max time.Duration, jitter float64) Backoff {
+	jitter = min(jitter, 1)
+	return func(attempt int) time.Duration {
+		d := initial
+		for i := 1; i < attempt && d < max; i++ {
+			d *= 2
+		}
+		d = min(d, max)
+		if jitter > 0 {
+			d -= time.Duration(jitter * rand.Float64() * float64(d))
+		}
+		return d
+	}
+}
+
+// RetryPolicy определяет повторную обработку сообщений, обработка которых
+// не удалась.
+type RetryPolicy struct {
+	// MaxAttempts — число попыток обработки сообщения, включая первую.
+	// Неположительное значение снимает ограничение.
+	MaxAttempts int
+	// Backoff вычисляет задержку перед каждой повторной попыткой.
+	// Если не задан, повтор выполняется сразу.
+	Backoff Backoff
+}
+
+func defaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: DefaultMaxDeliveries,
+		Backoff:     ExponentialBackoff(DefaultRedeliveryBackoff, DefaultMaxRedeliveryBackoff, 0),
+	}
+}
+
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	if p.Backoff == nil {
+		return 0
+	}
+	return p.Backoff(attempt)
+}
+
+// FailureHandler получает сообщение, которое не удалось обработать
+// за отведённое число попыток.
+type FailureHandler func(letter *DeadLetter)
+
+// SubscribeRetry подписывает обработчик, возвращающий ошибку: сообщение,
+// на котором обработчик вернул ошибку или запаниковал, обрабатывается
+// повторно согласно политике повторов (WithRetryPolicy). Пока сообщение
+// повторяется, следующие сообщения подписки ждут в очереди, поэтому порядок
+// обработки сохраняется. После исчерпания попыток сообщение передаётся
+// обработчику неудач (WithFailureHandler) и в субъект недоставленных
+// сообщений (WithDeadLetter).
+func (sp *subPub) SubscribeRetry(subject string, cb RetryHandler, opts ...SubscribeOption) (Subscription, error) {
+	return subscribed(sp.subscribe(subject, cb.deliver, append(opts[:len(opts):len(opts)], withRetries())))
+}
+
+func withRetries() SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.retry = true
+	}
+}
+
+// WithRetryPolicy задаёт политику повторов для SubscribeRetry и SubscribeAck.
+func WithRetryPolicy(policy RetryPolicy) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.retryPolicy = policy
+		o.retryOptions = true
+	}
+}
+
+// WithFailureHandler задаёт обработчик сообщений, которые не удалось
+// обработать за отведённое число попыток.
+func WithFailureHandler(h FailureHandler) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.onFailure = h
+		o.retryOptions = true
+	}
+}
+
+// invokeRetry обрабатывает сообщение, пока обработчик не завершится успешно
+// или не закончатся попытки. Если подписку остановили во время паузы перед
+// повтором, сообщение считается необработанным и передаётся в fail.
+// Вызывается только из горутины доставки.
+func (s *subscription) invokeRetry(env *envelope) {
+	policy := s.opts.retryPolicy
+	for attempt := 1; ; attempt++ {
+		delivery := *env
+		delivery.attempt = attempt
+
+		err := s.call(&delivery)
+		if err == nil {
+			return
+		}
+		if policy.MaxAttempts > 0 && attempt >= policy.MaxAttempts {
+			s.fail(env, attempt, err)
+			return
+		}
+
+		if !s.wait(policy.delay(attempt)) {
+			s.fail(env, attempt, fmt.Errorf("%w: %w", ErrRedeliveryStopped, err))
+			return
+		}
+		s.redelivered.Add(1)
+	}
+}
+
+// wait выдерживает паузу перед повторной попыткой. Возвращает false,
+// если подписка была остановлена.
+func (s *subscription) wait(d time.Duration) bool {
+	if d <= 0 {
+		select {
+		case <-s.done:
+			return false
+		default:
+			return true
+		}
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-s.done:
+		return false
+	}
+}
+
+// fail сообщает о сообщении, для которого исчерпаны попытки обработки.
+// Если не заданы ни субъект недоставленных сообщений, ни обработчик неудач,
+// сообщение передаётся в ErrorHook шины.
+func (s *subscription) fail(env *envelope, attempts int, reason error) {
+	s.deadLettered.Add(1)
+
+	letter := &DeadLetter{
+		Subject:  env.subject,
+		Pattern:  s.subject,
+		Group:    s.group,
+		Msg:      env.msg,
+		Attempts: attempts,
+		Reason:   reason,
+	}
+	if s.opts.deadLetter == "" && s.opts.onFailure == nil {
+		s.bus.reportError(letter)
+		return
+	}
+	if s.opts.onFailure != nil {
+		s.notifyFailure(letter)
+	}
+	if s.opts.deadLetter == "" {
+		return
+	}
+	if _, err := s.bus.publish(&envelope{subject: s.opts.deadLetter, msg: letter}); err != nil {
+		s.bus.reportError(fmt.Errorf("subpub: failed to publish dead letter to %q: %w", s.opts.deadLetter, err))
+	}
+}
+
+// notifyFailure вызывает обработчик неудач, не давая его панике
+// остановить горутину доставки.
+func (s *subscription) notifyFailure(letter *DeadLetter) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.bus.reportError(&PanicError{
+				Subject: s.subject,
+				Msg:     letter.Msg,
+				Value:   r,
+				Stack:   debug.Stack(),
+			})
+		}
+	}()
+	s.opts.onFailure(letter)
+}
@@ -0,0 +1,148 @@
+package subpub
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRetryPreservesOrder(t *testing.T) {
+	sp := NewSubPub()
+
+	var mu sync.Mutex
+	var processed []interface{}
+	failures := map[interface{}]int{1: 2}
+	done := make(chan struct{})
+	sub, err := sp.SubscribeRetry("test", func(_ context.Context, msg interface{}) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if failures[msg] > 0 {
+			failures[msg]--
+			return errHandler
+		}
+		processed = append(processed, msg)
+		if len(processed) == 3 {
+			close(done)
+		}
+		return nil
+	}, WithRetryPolicy(RetryPolicy{MaxAttempts: 5, Backoff: FixedBackoff(10 * time.Millisecond)}))
+	if err != nil {
+		t.Fatalf("SubscribeRetry failed: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	for i := 1; i <= 3; i++ {
+		sp.Publish("test", i)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("messages were not processed")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for i, msg := range processed {
+		if msg != i+1 {
+			t.Fatalf("processed = %v, want [1 2 3]", processed)
+		}
+	}
+	if stats := sub.Stats(); stats.Redelivered != 2 || stats.DeadLettered != 0 {
+		t.Errorf("stats = %+v, want 2 redelivered, 0 dead-lettered", stats)
+	}
+}
+
+func TestRetryFailureHandler(t *testing.T) {
+	sp := NewSubPub()
+
+	var attempts int
+	letters := make(chan *DeadLetter, 1)
+	sub, err := sp.SubscribeRetry("orders.*", func(_ context.Context, msg interface{}) error {
+		attempts++
+		return errHandler
+	}, WithRetryPolicy(RetryPolicy{MaxAttempts: 3}), WithFailureHandler(func(letter *DeadLetter) {
+		letters <- letter
+	}))
+	if err != nil {
+		t.Fatalf("SubscribeRetry failed: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	sp.Publish("orders.created", "order")
+
+	select {
+	case letter := <-letters:
+		if letter.Subject != "orders.created" || letter.Msg != "order" || letter.Attempts != 3 || letter.Reason != errHandler {
+			t.Errorf("letter = %+v", letter)
+		}
+		if attempts != 3 {
+			t.Errorf("handler called %d times, want 3", attempts)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("failure handler was not called")
+	}
+}
+
+func TestRetryPanicIsRetried(t *testing.T) {
+	errs := make(chan error, 1)
+	sp := NewSubPub(WithErrorHook(func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	}))
+
+	done := make(chan struct{})
+	calls := 0
+	sub, err := sp.SubscribeRetry("test", func(_ context.Context, msg interface{}) error {
+		calls++
+		if calls == 1 {
+			panic("boom")
+		}
+		close(done)
+		return nil
+	}, WithRetryPolicy(RetryPolicy{MaxAttempts: 2}))
+	if err != nil {
+		t.Fatalf("SubscribeRetry failed: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	sp.Publish("test", "msg")
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("message was not retried after panic")
+	}
+	if _, ok := (<-errs).(*PanicError); !ok {
+		t.Error("panic was not reported")
+	}
+}
+
+func TestFixedBackoff(t *testing.T) {
+	backoff := FixedBackoff(50 * time.Millisecond)
+	for attempt := 1; attempt <= 3; attempt++ {
+		if d := backoff(attempt); d != 50*time.Millisecond {
+			t.Errorf("backoff(%d) = %v, want 50ms", attempt, d)
+		}
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(10*time.Millisecond, 50*time.Millisecond, 0)
+	want := []time.Duration{10, 20, 40, 50, 50}
+	for i, w := range want {
+		if d := backoff(i + 1); d != w*time.Millisecond {
+			t.Errorf("backoff(%d) = %v, want %v", i+1, d, w*time.Millisecond)
+		}
+	}
+
+	jittered := ExponentialBackoff(100*time.Millisecond, time.Second, 0.5)
+	for i := 0; i < 100; i++ {
+		if d := jittered(1); d < 50*time.Millisecond || d > 100*time.Millisecond {
+			t.Fatalf("jittered backoff = %v, want within [50ms, 100ms]", d)
+		}
+	}
+}
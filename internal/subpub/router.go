@@ -0,0 +1,263 @@
+package subpub
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// shardCount — число сегментов таблицы точных субъектов.
+const shardCount = 32
+
+// minSequenceLimit — наименьшее число счётчиков номеров в сегменте,
+// при превышении которого удаляются счётчики субъектов без подписок.
+const minSequenceLimit = 64
+
+// router хранит подписки и выбирает получателей сообщений.
+// Подписки на точные субъекты распределены по сегментам с собственными
+// блокировками, поэтому публикации и подписки на разные субъекты
+// не конкурируют между собой. Шаблоны с подстановочными токенами хранятся
+// в отдельном дереве, которое при публикации не обходится, пока в нём нет
+// ни одной подписки.
+//
+// Порядок захвата блокировок: сегменты по возрастанию номера, затем дерево шаблонов.
+type router struct {
+	shards [shardCount]shard
+
+	wmu       sync.RWMutex
+	wildcards *trie
+	// wildcardCount — число подписок в дереве шаблонов, читается без блокировки
+	wildcardCount atomic.Int64
+}
+
+type shard struct {
+	mu       sync.RWMutex
+	subjects map[string]*subscriberSet
+	// sequences — номера последних сообщений субъектов сегмента. Номера
+	// выдаются под блокировкой чтения mu, поэтому защищены отдельной seqMu
+	seqMu     sync.Mutex
+	sequences map[string]uint64
+	// seqLimit — размер sequences, после которого выполняется pruneSequences
+	seqLimit int
+	// выравнивание исключает ложное разделение кэш-линий между соседними сегментами
+	_ [16]byte
+}
+
+func newRouter() *router {
+	r := &router{wildcards: newTrie()}
+	for i := range r.shards {
+		r.shards[i].subjects = make(map[string]*subscriberSet)
+		r.shards[i].sequences = make(map[string]uint64)
+		r.shards[i].seqLimit = minSequenceLimit
+	}
+	return r
+}
+
+// shard возвращает сегмент точного субъекта по хешу FNV-1a.
+func (r *router) shard(subject string) *shard {
+	const (
+		offset = 2166136261
+		prime  = 16777619
+	)
+	h := uint32(offset)
+	for i := 0; i < len(subject); i++ {
+		h ^= uint32(subject[i])
+		h *= prime
+	}
+	return &r.shards[h%shardCount]
+}
+
+// lock возвращает блокировку, под которой хранятся подписки на субъект.
+func (r *router) lock(subject string, wildcard bool) *sync.RWMutex {
+	if wildcard {
+		return &r.wmu
+	}
+	return &r.shard(subject).mu
+}
+
+// insert добавляет подписку. Вызывается под блокировкой записи lock.
+func (r *router) insert(sub *subscription) {
+	if sub.wildcard {
+		r.wildcards.insert(sub.tokens, sub)
+		r.wildcardCount.Add(1)
+		return
+	}
+
+	s := r.shard(sub.subject)
+	set, ok := s.subjects[sub.subject]
+	if !ok {
+		set = &subscriberSet{}
+		s.subjects[sub.subject] = set
+	}
+	set.add(sub)
+}
+
+// remove удаляет подписку. Вызывается под блокировкой записи lock.
+// Для участника группы очередей возвращает группу, если в ней остались
+// другие участники.
+func (r *router) remove(sub *subscription) (bool, *queueGroup) {
+	if sub.wildcard {
+		ok, group := r.wildcards.remove(sub.tokens, sub)
+		if ok {
+			r.wildcardCount.Add(-1)
+		}
+		return ok, group
+	}
+
+	s := r.shard(sub.subject)
+	set, ok := s.subjects[sub.subject]
+	if !ok {
+		return false, nil
+	}
+	ok, group := set.remove(sub)
+	if set.empty() {
+		delete(s.subjects, sub.subject)
+	}
+	return ok, group
+}
+
+// hasWildcards сообщает, есть ли подписки на шаблоны.
+func (r *router) hasWildcards() bool {
+	return r.wildcardCount.Load() > 0
+}
+
+// nextSequence выдаёт номер очередного сообщения субъекта и сообщает,
+// пора ли удалить счётчики субъектов без подписок (pruneSequences).
+// Вызывается под блокировкой чтения сегмента субъекта.
+func (s *shard) nextSequence(subject string) (uint64, bool) {
+	s.seqMu.Lock()
+	defer s.seqMu.Unlock()
+
+	seq := s.sequences[subject] + 1
+	s.sequences[subject] = seq
+	return seq, len(s.sequences) > s.seqLimit
+}
+
+// pruneSequences удаляет счётчики номеров субъектов сегмента, на которые
+// нет ни одной подписки и у которых нет сохранённых в retained сообщений
+// (иначе новый подписчик после воспроизведения получил бы номера с начала),
+// и поднимает порог следующей очистки вдвое выше
+// числа оставшихся счётчиков. Так число счётчиков остаётся пропорциональным
+// числу субъектов с подписками, а очистка в среднем обходится в O(1)
+// на публикацию в новый субъект.
+func (r *router) pruneSequences(s *shard, retained *retainedStore) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r.wmu.RLock()
+	defer r.wmu.RUnlock()
+
+	// Под блокировкой записи сегмента номера не выдаются, seqMu не нужна
+	if len(s.sequences) <= s.seqLimit {
+		return
+	}
+	var buf []*subscription
+	for subject := range s.sequences {
+		if _, ok := s.subjects[subject]; ok {
+			continue
+		}
+		if buf = r.matchWildcards(subject, buf[:0]); len(buf) > 0 {
+			continue
+		}
+		if retained.has(subject) {
+			continue
+		}
+		delete(s.sequences, subject)
+	}
+	clear(buf)
+	s.seqLimit = max(2*len(s.sequences), minSequenceLimit)
+}
+
+// matchExact добавляет к dst получателей точного субъекта.
+// Вызывается под блокировкой чтения сегмента субъекта.
+func (r *router) matchExact(s *shard, subject string, dst []*subscription) []*subscription {
+	if set, ok := s.subjects[subject]; ok {
+		dst = set.collect(dst)
+	}
+	return dst
+}
+
+// matchWildcards добавляет к dst получателей среди подписок на шаблоны.
+// Вызывается под блокировкой чтения wmu.
+func (r *router) matchWildcards(subject string, dst []*subscription) []*subscription {
+	if r.wildcards.size == 0 {
+		return dst
+	}
+	return r.wildcards.match(splitSubject(subject), dst)
+}
+
+// matchPattern добавляет к dst получателей среди подписок на сам шаблон
+// pattern, без подписок на другие шаблоны, совпадающие с ним.
+// Вызывается под блокировкой чтения lock(pattern, wildcard).
+func (r *router) matchPattern(pattern string, tokens []string, wildcard bool, dst []*subscription) []*subscription {
+	if !wildcard {
+		return r.matchExact(r.shard(pattern), pattern, dst)
+	}
+	if n := r.wildcards.find(tokens); n != nil {
+		dst = n.collect(dst)
+	}
+	return dst
+}
+
+// lockAll захватывает блокировки записи всех сегментов и дерева шаблонов.
+func (r *router) lockAll() {
+	for i := range r.shards {
+		r.shards[i].mu.Lock()
+	}
+	r.wmu.Lock()
+}
+
+func (r *router) unlockAll() {
+	r.wmu.Unlock()
+	for i := range r.shards {
+		r.shards[i].mu.Unlock()
+	}
+}
+
+// rLockAll захватывает блокировки чтения всех сегментов и дерева шаблонов.
+func (r *router) rLockAll() {
+	for i := range r.shards {
+		r.shards[i].mu.RLock()
+	}
+	r.wmu.RLock()
+}
+
+func (r *router) rUnlockAll() {
+	r.wmu.RUnlock()
+	for i := range r.shards {
+		r.shards[i].mu.RUnlock()
+	}
+}
+
+// walk обходит все субъекты и шаблоны, на которые есть подписки.
+// Вызывается под rLockAll или lockAll.
+func (r *router) walk(fn func(subject string, set *subscriberSet)) {
+	for i := range r.shards {
+		for subject, set := range r.shards[i].subjects {
+			fn(subject, set)
+		}
+	}
+	r.wildcards.walk(func(tokens []string, set *subscriberSet) {
+		fn(joinTokens(tokens), set)
+	})
+}
+
+// reset удаляет все подписки и возвращает их. Вызывается под lockAll.
+func (r *router) reset() []*subscription {
+	var subs []*subscription
+	r.walk(func(_ string, set *subscriberSet) {
+		subs = set.all(subs)
+	})
+	for i := range r.shards {
+		r.shards[i].subjects = make(map[string]*subscriberSet)
+	}
+	r.wildcards = newTrie()
+	r.wildcardCount.Store(0)
+	return subs
+}
+
+// matchPool переиспользует буферы получателей между публикациями.
+var matchPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]*subscription, 0, 16)
+		return &buf
+	},
+}
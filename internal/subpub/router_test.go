@@ -0,0 +1,113 @@
+package subpub
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// assertRoutesEmpty проверяет, что в маршрутизаторе не осталось записей субъектов и узлов шаблонов.
+func assertRoutesEmpty(t *testing.T, sp *subPub) {
+	t.Helper()
+
+	if infos := sp.Snapshot(); len(infos) != 0 {
+		t.Errorf("Snapshot: got %d subjects, want none", len(infos))
+	}
+	for i := range sp.routes.shards {
+		s := &sp.routes.shards[i]
+		s.mu.RLock()
+		n := len(s.subjects)
+		s.mu.RUnlock()
+		if n != 0 {
+			t.Errorf("shard %d keeps %d subject entries", i, n)
+		}
+	}
+
+	sp.routes.wmu.RLock()
+	defer sp.routes.wmu.RUnlock()
+	if n := len(sp.routes.wildcards.root.children); n != 0 {
+		t.Errorf("wildcard trie keeps %d root nodes", n)
+	}
+	if n := sp.routes.wildcards.size; n != 0 {
+		t.Errorf("wildcard trie size: got %d, want 0", n)
+	}
+	if n := sp.routes.wildcardCount.Load(); n != 0 {
+		t.Errorf("wildcard count: got %d, want 0", n)
+	}
+}
+
+func TestUnsubscribeReclaimsRoutes(t *testing.T) {
+	sp := NewSubPub().(*subPub)
+
+	subjects := []string{
+		"orders",
+		"orders.created",
+		"orders.*",
+		"orders.*.eu",
+		"orders.>",
+		"*.created",
+		">",
+	}
+
+	var subs []Subscription
+	for i := 0; i < 100; i++ {
+		for _, subject := range subjects {
+			sub, err := sp.Subscribe(subject, func(interface{}) {})
+			if err != nil {
+				t.Fatalf("Subscribe(%q) failed: %v", subject, err)
+			}
+			subs = append(subs, sub)
+
+			sub, err = sp.SubscribeQueue(subject, fmt.Sprintf("group-%d", i%3), func(interface{}) {})
+			if err != nil {
+				t.Fatalf("SubscribeQueue(%q) failed: %v", subject, err)
+			}
+			subs = append(subs, sub)
+		}
+		sub, err := sp.Subscribe(fmt.Sprintf("users.%d.events", i), func(interface{}) {})
+		if err != nil {
+			t.Fatalf("Subscribe failed: %v", err)
+		}
+		subs = append(subs, sub)
+	}
+
+	for _, sub := range subs {
+		sub.Unsubscribe()
+	}
+	assertRoutesEmpty(t, sp)
+}
+
+func TestUnsubscribeReclaimsRoutesConcurrently(t *testing.T) {
+	sp := NewSubPub().(*subPub)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				subject := fmt.Sprintf("churn.%d.%d", g, i%10)
+				if i%2 == 0 {
+					subject = fmt.Sprintf("churn.*.%d", i%10)
+				}
+
+				var sub Subscription
+				var err error
+				if i%3 == 0 {
+					sub, err = sp.SubscribeQueue(subject, "workers", func(interface{}) {})
+				} else {
+					sub, err = sp.Subscribe(subject, func(interface{}) {})
+				}
+				if err != nil {
+					t.Errorf("subscribe to %q failed: %v", subject, err)
+					return
+				}
+				sp.Publish(fmt.Sprintf("churn.%d.%d", g, i%10), i)
+				sub.Unsubscribe()
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	assertRoutesEmpty(t, sp)
+}
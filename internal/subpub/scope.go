@@ -0,0 +1,314 @@
+package subpub
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Scoped возвращает представление шины с пространством имён prefix: субъекты
+// подписок, публикаций и запросов представления, а также субъект
+// недоставленных сообщений (WithDeadLetter) дополняются префиксом, а Snapshot
+// и Message.Subject у обработчиков SubscribeMsg показывают субъекты
+// пространства имён без префикса. Обычно префикс
+// оканчивается точкой, например "billing.". Префикс с подстановочными токенами
+// недопустим: все методы такого представления возвращают ErrInvalidSubject.
+//
+// Close представления отписывает только подписки, созданные через него
+// и через вложенные в него представления, и не закрывает саму шину.
+func (sp *subPub) Scoped(prefix string) SubPub {
+	return newScope(sp, nil, prefix)
+}
+
+// scope — представление шины с префиксом субъектов.
+type scope struct {
+	bus    *subPub
+	parent *scope
+	prefix string
+	// err не nil, если префикс недопустим
+	err error
+
+	// closed изменяется под mu, а читается без блокировки
+	closed atomic.Bool
+	mu     sync.Mutex
+	subs   map[*subscription]struct{}
+	// prune — размер subs, при котором из него удаляются завершённые подписки
+	prune int
+}
+
+// minScopePrune — размер набора подписок представления, до которого
+// завершённые подписки из него не удаляются.
+const minScopePrune = 16
+
+func newScope(bus *subPub, parent *scope, prefix string) *scope {
+	s := &scope{
+		bus:    bus,
+		parent: parent,
+		prefix: prefix,
+		subs:   make(map[*subscription]struct{}),
+		prune:  minScopePrune,
+	}
+	if parent != nil {
+		s.prefix = parent.prefix + prefix
+		s.err = parent.err
+	}
+	if s.err == nil && strings.ContainsAny(prefix, tokenWildcard+tokenTail) {
+		s.err = ErrInvalidSubject
+	}
+	return s
+}
+
+func (s *scope) Scoped(prefix string) SubPub {
+	return newScope(s.bus, s, prefix)
+}
+
+// subject возвращает полный субъект шины для субъекта представления.
+func (s *scope) subject(subject string) (string, error) {
+	if s.err != nil {
+		return "", s.err
+	}
+	if s.isClosed() {
+		return "", ErrClosed
+	}
+	return s.prefix + subject, nil
+}
+
+// options дополняет параметры подписки префиксом субъекта недоставленных сообщений.
+func (s *scope) options(opts []SubscribeOption) []SubscribeOption {
+	return append(opts[:len(opts):len(opts)], func(o *subscribeOptions) {
+		if o.deadLetter != "" {
+			o.deadLetter = s.prefix + o.deadLetter
+		}
+	})
+}
+
+func (s *scope) Subscribe(subject string, cb MessageHandler, opts ...SubscribeOption) (Subscription, error) {
+	subject, err := s.subject(subject)
+	if err != nil {
+		return nil, err
+	}
+	return s.track(s.bus.Subscribe(subject, cb, s.options(opts)...))
+}
+
+func (s *scope) SubscribeQueue(subject, group string, cb MessageHandler, opts ...SubscribeOption) (Subscription, error) {
+	subject, err := s.subject(subject)
+	if err != nil {
+		return nil, err
+	}
+	return s.track(s.bus.SubscribeQueue(subject, group, cb, s.options(opts)...))
+}
+
+func (s *scope) SubscribeContext(subject string, cb ContextHandler, opts ...SubscribeOption) (Subscription, error) {
+	subject, err := s.subject(subject)
+	if err != nil {
+		return nil, err
+	}
+	return s.track(s.bus.SubscribeContext(subject, cb, s.options(opts)...))
+}
+
+func (s *scope) SubscribeMsg(subject string, cb MsgHandler, opts ...SubscribeOption) (Subscription, error) {
+	subject, err := s.subject(subject)
+	if err != nil {
+		return nil, err
+	}
+	// Получатели видят субъект без префикса, как и остальные субъекты представления
+	return s.track(s.bus.SubscribeMsg(subject, func(msg *Message) {
+		msg.Subject = strings.TrimPrefix(msg.Subject, s.prefix)
+		cb(msg)
+	}, s.options(opts)...))
+}
+
+func (s *scope) SubscribeRequests(subject string, cb RequestHandler, opts ...SubscribeOption) (Subscription, error) {
+	subject, err := s.subject(subject)
+	if err != nil {
+		return nil, err
+	}
+	return s.track(s.bus.SubscribeRequests(subject, cb, s.options(opts)...))
+}
+
+func (s *scope) SubscribeAck(subject string, cb AckHandler, opts ...SubscribeOption) (Subscription, error) {
+	subject, err := s.subject(subject)
+	if err != nil {
+		return nil, err
+	}
+	return s.track(s.bus.SubscribeAck(subject, cb, s.options(opts)...))
+}
+
+func (s *scope) SubscribeRetry(subject string, cb RetryHandler, opts ...SubscribeOption) (Subscription, error) {
+	subject, err := s.subject(subject)
+	if err != nil {
+		return nil, err
+	}
+	return s.track(s.bus.SubscribeRetry(subject, cb, s.options(opts)...))
+}
+
+func (s *scope) SubscribeBatch(subject string, maxSize int, maxWait time.Duration, cb BatchHandler, opts ...SubscribeOption) (Subscription, error) {
+	subject, err := s.subject(subject)
+	if err != nil {
+		return nil, err
+	}
+	return s.track(s.bus.SubscribeBatch(subject, maxSize, maxWait, cb, s.options(opts)...))
+}
+
+func (s *scope) SubscribeChan(subject string, bufSize int, opts ...SubscribeOption) (<-chan interface{}, Subscription, error) {
+	subject, err := s.subject(subject)
+	if err != nil {
+		return nil, nil, err
+	}
+	ch, sub, err := s.bus.SubscribeChan(subject, bufSize, s.options(opts)...)
+	if sub, err = s.track(sub, err); err != nil {
+		return nil, nil, err
+	}
+	return ch, sub, nil
+}
+
+// track запоминает подписку в представлении и во всех объемлющих
+// представлениях. Если какое-то из них уже закрыто, подписка отменяется.
+func (s *scope) track(sub Subscription, err error) (Subscription, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	created := sub.(*subscription)
+	for v := s; v != nil; v = v.parent {
+		if !v.add(created) {
+			created.Unsubscribe()
+			return nil, ErrClosed
+		}
+	}
+	return sub, nil
+}
+
+func (s *scope) add(sub *subscription) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed.Load() {
+		return false
+	}
+	if len(s.subs) >= s.prune {
+		for old := range s.subs {
+			if isClosed(old.finished) {
+				delete(s.subs, old)
+			}
+		}
+		s.prune = max(2*len(s.subs), minScopePrune)
+	}
+	s.subs[sub] = struct{}{}
+	return true
+}
+
+func (s *scope) isClosed() bool {
+	for v := s; v != nil; v = v.parent {
+		if v.closed.Load() {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *scope) Publish(subject string, msg interface{}) error {
+	subject, err := s.subject(subject)
+	if err != nil {
+		return err
+	}
+	return s.bus.Publish(subject, msg)
+}
+
+func (s *scope) PublishMsg(msg *Message) error {
+	subject, err := s.subject(msg.Subject)
+	if err != nil {
+		return err
+	}
+	scoped := *msg
+	scoped.Subject = subject
+	return s.bus.PublishMsg(&scoped)
+}
+
+func (s *scope) Request(ctx context.Context, subject string, msg interface{}) (interface{}, error) {
+	subject, err := s.subject(subject)
+	if err != nil {
+		return nil, err
+	}
+	return s.bus.Request(ctx, subject, msg)
+}
+
+func (s *scope) RequestMany(ctx context.Context, subject string, msg interface{}, max int) ([]interface{}, error) {
+	subject, err := s.subject(subject)
+	if err != nil {
+		return nil, err
+	}
+	return s.bus.RequestMany(ctx, subject, msg, max)
+}
+
+func (s *scope) ClearRetained(subject string) error {
+	subject, err := s.subject(subject)
+	if err != nil {
+		return err
+	}
+	return s.bus.ClearRetained(subject)
+}
+
+// Snapshot возвращает субъекты пространства имён представления без префикса.
+func (s *scope) Snapshot() []SubjectInfo {
+	if s.err != nil {
+		return nil
+	}
+
+	var infos []SubjectInfo
+	for _, info := range s.bus.Snapshot() {
+		subject, ok := strings.CutPrefix(info.Subject, s.prefix)
+		if !ok || subject == "" {
+			continue
+		}
+		info.Subject = subject
+		for i := range info.Subscriptions {
+			info.Subscriptions[i].Subject = subject
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// Close отписывает подписки представления, дожидаясь доставки уже
+// поставленных в их очереди сообщений. Если ctx истекает раньше,
+// недоставленные сообщения отбрасываются, а Close возвращает ошибку контекста.
+func (s *scope) Close(ctx context.Context) error {
+	s.mu.Lock()
+	var subs []*subscription
+	if !s.closed.Load() {
+		s.closed.Store(true)
+		subs = make([]*subscription, 0, len(s.subs))
+		for sub := range s.subs {
+			subs = append(subs, sub)
+		}
+		s.subs = nil
+	}
+	s.mu.Unlock()
+
+	return s.bus.drainSubscriptions(ctx, subs)
+}
+
+// drainSubscriptions исключает подписки из списка получателей и ждёт, пока
+// они доставят уже поставленные в очередь сообщения. Если ctx истекает
+// раньше, доставка прекращается, а недоставленные сообщения отбрасываются.
+func (sp *subPub) drainSubscriptions(ctx context.Context, subs []*subscription) error {
+	for _, sub := range subs {
+		sp.remove(sub)
+		sub.drain()
+	}
+
+	for _, sub := range subs {
+		select {
+		case <-sub.finished:
+		case <-ctx.Done():
+			for _, sub := range subs {
+				sub.stop()
+			}
+			return ctx.Err()
+		}
+	}
+	return nil
+}
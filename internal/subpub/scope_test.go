@@ -0,0 +1,162 @@
+package subpub
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestScopedPrefixesSubjects(t *testing.T) {
+	sp := NewSubPub()
+	billing := sp.Scoped("billing.")
+
+	scoped, sub := subscribeCollector(t, billing, "invoices.*")
+	defer sub.Unsubscribe()
+	global, gsub := subscribeCollector(t, sp, "billing.invoices.created")
+	defer gsub.Unsubscribe()
+
+	if err := billing.Publish("invoices.created", 1); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if err := sp.Publish("billing.invoices.paid", 2); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	if got := scoped.waitFor(t, 2); got[0] != 1 || got[1] != 2 {
+		t.Errorf("scoped subscriber received %v, want [1 2]", got)
+	}
+	if got := global.waitFor(t, 1); got[0] != 1 {
+		t.Errorf("global subscriber received %v, want [1]", got)
+	}
+}
+
+func TestScopedIsolation(t *testing.T) {
+	sp := NewSubPub()
+	billing := sp.Scoped("billing.")
+	shipping := sp.Scoped("shipping.")
+
+	received := make(chan interface{}, 1)
+	sub, err := shipping.Subscribe("orders.created", func(msg interface{}) {
+		received <- msg
+	})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	billing.Publish("orders.created", "msg")
+
+	select {
+	case msg := <-received:
+		t.Errorf("shipping received %v published by billing", msg)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestScopedCloseOnlyOwnSubscriptions(t *testing.T) {
+	sp := NewSubPub()
+	billing := sp.Scoped("billing.")
+
+	b := subscribeBlocking(t, billing, "events")
+	other, osub := subscribeCollector(t, sp, "billing.events")
+	defer osub.Unsubscribe()
+
+	billing.Publish("events", 1)
+	<-b.started
+	billing.Publish("events", 2)
+
+	closed := make(chan error, 1)
+	go func() {
+		closed <- billing.Close(context.Background())
+	}()
+	select {
+	case err := <-closed:
+		t.Fatalf("Close returned %v before queued messages were delivered", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(b.release)
+	if err := <-closed; err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	b.expect(t, 1, 2)
+
+	if err := billing.Publish("events", 3); err != ErrClosed {
+		t.Errorf("Publish after Close: got %v, want %v", err, ErrClosed)
+	}
+	if _, err := billing.Subscribe("events", func(interface{}) {}); err != ErrClosed {
+		t.Errorf("Subscribe after Close: got %v, want %v", err, ErrClosed)
+	}
+
+	// Шина и подписки вне представления продолжают работать
+	if err := sp.Publish("billing.events", 4); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if got := other.waitFor(t, 3); got[2] != 4 {
+		t.Errorf("other subscriber received %v, want [1 2 4]", got)
+	}
+}
+
+func TestScopedNested(t *testing.T) {
+	sp := NewSubPub()
+	billing := sp.Scoped("billing.")
+	invoices := billing.Scoped("invoices.")
+
+	c, _ := subscribeCollector(t, invoices, "created")
+	sp.Publish("billing.invoices.created", "msg")
+	c.waitFor(t, 1)
+
+	if err := billing.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := invoices.Publish("created", "msg"); err != ErrClosed {
+		t.Errorf("Publish in nested view after parent Close: got %v, want %v", err, ErrClosed)
+	}
+	if infos := sp.Snapshot(); len(infos) != 0 {
+		t.Errorf("Snapshot after Close = %+v, want no subscriptions", infos)
+	}
+}
+
+func TestScopedSnapshot(t *testing.T) {
+	sp := NewSubPub()
+	billing := sp.Scoped("billing.")
+
+	_, sub1 := subscribeCollector(t, billing, "invoices.>")
+	defer sub1.Unsubscribe()
+	_, sub2 := subscribeCollector(t, sp, "shipping.orders")
+	defer sub2.Unsubscribe()
+
+	infos := billing.Snapshot()
+	if len(infos) != 1 || infos[0].Subject != "invoices.>" || infos[0].Subscriptions[0].Subject != "invoices.>" {
+		t.Errorf("Snapshot = %+v, want only invoices.>", infos)
+	}
+}
+
+func TestScopedDeadLetter(t *testing.T) {
+	sp := NewSubPub()
+	billing := sp.Scoped("billing.")
+
+	letters, lsub := subscribeCollector(t, sp, "billing.dead")
+	defer lsub.Unsubscribe()
+
+	sub, err := billing.SubscribeRetry("jobs", func(context.Context, interface{}) error {
+		return errHandler
+	}, WithRetryPolicy(RetryPolicy{MaxAttempts: 1}), WithDeadLetter("dead"))
+	if err != nil {
+		t.Fatalf("SubscribeRetry failed: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	billing.Publish("jobs", "job")
+	if letter := letters.waitFor(t, 1)[0].(*DeadLetter); letter.Subject != "billing.jobs" {
+		t.Errorf("dead letter subject = %q, want %q", letter.Subject, "billing.jobs")
+	}
+}
+
+func TestScopedInvalidPrefix(t *testing.T) {
+	sp := NewSubPub()
+	for _, prefix := range []string{"*.", "billing.>."} {
+		if err := sp.Scoped(prefix).Publish("a", "msg"); err != ErrInvalidSubject {
+			t.Errorf("Publish in Scoped(%q): got %v, want %v", prefix, err, ErrInvalidSubject)
+		}
+	}
+}
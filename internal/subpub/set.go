@@ -0,0 +1,100 @@
+package subpub
+
+// subscriberSet — подписки на один субъект или шаблон. Подписка хранит свою
+// позицию в срезе (subscription.index), поэтому удаляется за O(1), а Publish
+// обходит плотный срез без копирования.
+// Не потокобезопасно, синхронизация лежит на владельце.
+type subscriberSet struct {
+	subs   []*subscription
+	groups map[string]*queueGroup
+}
+
+func (s *subscriberSet) empty() bool {
+	return len(s.subs) == 0 && len(s.groups) == 0
+}
+
+func (s *subscriberSet) add(sub *subscription) {
+	if sub.group == "" {
+		s.subs = appendIndexed(s.subs, sub)
+		return
+	}
+
+	if s.groups == nil {
+		s.groups = make(map[string]*queueGroup)
+	}
+	g, ok := s.groups[sub.group]
+	if !ok {
+		g = &queueGroup{}
+		s.groups[sub.group] = g
+	}
+	g.members = appendIndexed(g.members, sub)
+}
+
+// remove удаляет подписку. Для участника группы очередей также возвращается
+// его группа, если в ней остались другие участники.
+func (s *subscriberSet) remove(sub *subscription) (bool, *queueGroup) {
+	if sub.group == "" {
+		var ok bool
+		s.subs, ok = removeIndexed(s.subs, sub)
+		return ok, nil
+	}
+
+	g, ok := s.groups[sub.group]
+	if !ok {
+		return false, nil
+	}
+	if g.members, ok = removeIndexed(g.members, sub); !ok {
+		return false, nil
+	}
+	if len(g.members) == 0 {
+		delete(s.groups, sub.group)
+		return true, nil
+	}
+	return true, g
+}
+
+// collect добавляет к dst все обычные подписки и по одному участнику от каждой группы очередей.
+func (s *subscriberSet) collect(dst []*subscription) []*subscription {
+	dst = append(dst, s.subs...)
+	for _, g := range s.groups {
+		dst = append(dst, g.pick())
+	}
+	return dst
+}
+
+// all добавляет к dst все подписки, включая всех участников групп.
+func (s *subscriberSet) all(dst []*subscription) []*subscription {
+	dst = append(dst, s.subs...)
+	for _, g := range s.groups {
+		dst = append(dst, g.members...)
+	}
+	return dst
+}
+
+func (s *subscriberSet) len() int {
+	n := len(s.subs)
+	for _, g := range s.groups {
+		n += len(g.members)
+	}
+	return n
+}
+
+func appendIndexed(list []*subscription, sub *subscription) []*subscription {
+	sub.index = len(list)
+	return append(list, sub)
+}
+
+// removeIndexed удаляет подписку, перемещая на её место последний элемент.
+func removeIndexed(list []*subscription, sub *subscription) ([]*subscription, bool) {
+	i := sub.index
+	if i < 0 || i >= len(list) || list[i] != sub {
+		return list, false
+	}
+
+	last := len(list) - 1
+	list[i] = list[last]
+	list[i].index = i
+	list[last] = nil
+	sub.index = -1
+	return list[:last], true
+}
@@ -0,0 +1,86 @@
+package subpub
+
+import (
+	"sort"
+	"time"
+)
+
+// SubscriptionStats — снимок состояния подписки.
+type SubscriptionStats struct {
+	// Subject — субъект или шаблон подписки
+	Subject string
+	// Group — группа очередей, пустая для обычной подписки
+	Group string
+	// Delivered — число сообщений, переданных обработчику
+	Delivered uint64
+	// Pending — число сообщений, ожидающих доставки в очереди
+	Pending int
+	// InFlight — число сообщений, обрабатываемых в данный момент
+	InFlight int
+	// Dropped — число сообщений, отброшенных из-за переполнения очереди
+	Dropped uint64
+	// Filtered — число сообщений, отклонённых фильтрами подписки
+	Filtered uint64
+	// Panicked — число паник, перехваченных в обработчике
+	Panicked uint64
+	// Redelivered — число повторных доставок в режиме подтверждений и с повтором обработки
+	Redelivered uint64
+	// DeadLettered — число сообщений, не обработанных за отведённое число попыток
+	DeadLettered uint64
+	// Paused — доставка приостановлена через Pause
+	Paused bool
+	// LastDelivery — время последней доставки, нулевое, если доставок не было
+	LastDelivery time.Time
+}
+
+// SubjectInfo описывает подписки на один субъект или шаблон.
+type SubjectInfo struct {
+	Subject       string
+	Subscribers   int
+	Subscriptions []SubscriptionStats
+}
+
+func (s *subscription) Stats() SubscriptionStats {
+	stats := SubscriptionStats{
+		Subject:      s.subject,
+		Group:        s.group,
+		Delivered:    s.delivered.Load(),
+		Pending:      s.pending(),
+		InFlight:     int(s.inFlight.Load()),
+		Dropped:      s.dropped.Load(),
+		Filtered:     s.filtered.Load(),
+		Panicked:     s.panicked.Load(),
+		Redelivered:  s.redelivered.Load(),
+		DeadLettered: s.deadLettered.Load(),
+		Paused:       s.paused.Load(),
+	}
+	if last := s.lastDelivery.Load(); last != 0 {
+		stats.LastDelivery = time.Unix(0, last)
+	}
+	return stats
+}
+
+// Snapshot возвращает субъекты, на которые есть подписки, отсортированные
+// по имени, вместе со статистикой каждой подписки.
+func (sp *subPub) Snapshot() []SubjectInfo {
+	sp.routes.rLockAll()
+	var infos []SubjectInfo
+	sp.routes.walk(func(subject string, set *subscriberSet) {
+		subs := set.all(nil)
+		info := SubjectInfo{
+			Subject:       subject,
+			Subscribers:   len(subs),
+			Subscriptions: make([]SubscriptionStats, 0, len(subs)),
+		}
+		for _, sub := range subs {
+			info.Subscriptions = append(info.Subscriptions, sub.Stats())
+		}
+		infos = append(infos, info)
+	})
+	sp.routes.rUnlockAll()
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Subject < infos[j].Subject
+	})
+	return infos
+}
@@ -0,0 +1,110 @@
+package subpub
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSubscriptionStats(t *testing.T) {
+	sp := NewSubPub(WithErrorHook(func(error) {}))
+
+	b := subscribeBlocking(t, sp, "test", WithBufferSize(2))
+	defer b.sub.Unsubscribe()
+
+	before := time.Now()
+	sp.Publish("test", 0)
+	<-b.started
+	for i := 1; i <= 3; i++ {
+		sp.Publish("test", i)
+	}
+
+	stats := b.sub.Stats()
+	if stats.Subject != "test" {
+		t.Errorf("Subject = %q, want %q", stats.Subject, "test")
+	}
+	if stats.Delivered != 1 || stats.Pending != 2 || stats.Dropped != 1 {
+		t.Errorf("stats before release = %+v, want 1 delivered, 2 pending, 1 dropped", stats)
+	}
+	if stats.LastDelivery.Before(before) {
+		t.Errorf("LastDelivery = %v, want after %v", stats.LastDelivery, before)
+	}
+
+	close(b.release)
+	b.expect(t, 0, 1, 2)
+
+	stats = b.sub.Stats()
+	if stats.Delivered != 3 || stats.Pending != 0 {
+		t.Errorf("stats after release = %+v, want 3 delivered, 0 pending", stats)
+	}
+}
+
+func TestSubscriptionStatsPanicked(t *testing.T) {
+	sp := NewSubPub(WithErrorHook(func(error) {}))
+
+	done := make(chan struct{})
+	sub, err := sp.Subscribe("test", func(msg interface{}) {
+		if msg == "last" {
+			close(done)
+		}
+		panic(msg)
+	})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	sp.Publish("test", "first")
+	sp.Publish("test", "last")
+	<-done
+	time.Sleep(10 * time.Millisecond)
+
+	if stats := sub.Stats(); stats.Panicked != 2 || stats.Delivered != 2 {
+		t.Errorf("stats = %+v, want 2 delivered and 2 panicked", stats)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	sp := NewSubPub()
+
+	subjects := []struct {
+		subject string
+		group   string
+	}{
+		{"orders.created", ""},
+		{"orders.created", ""},
+		{"orders.*", ""},
+		{"metrics.>", "collectors"},
+		{"metrics.>", "collectors"},
+	}
+	for _, s := range subjects {
+		sub, err := sp.Subscribe(s.subject, func(msg interface{}) {}, WithQueueGroup(s.group))
+		if err != nil {
+			t.Fatalf("Subscribe failed: %v", err)
+		}
+		defer sub.Unsubscribe()
+	}
+
+	snapshot := sp.Snapshot()
+	want := []struct {
+		subject     string
+		subscribers int
+	}{
+		{"metrics.>", 2},
+		{"orders.*", 1},
+		{"orders.created", 2},
+	}
+	if len(snapshot) != len(want) {
+		t.Fatalf("Snapshot returned %d subjects, want %d: %+v", len(snapshot), len(want), snapshot)
+	}
+	for i, w := range want {
+		got := snapshot[i]
+		if got.Subject != w.subject || got.Subscribers != w.subscribers || len(got.Subscriptions) != w.subscribers {
+			t.Errorf("snapshot[%d] = %+v, want subject %q with %d subscribers", i, got, w.subject, w.subscribers)
+		}
+	}
+	for _, stats := range snapshot[0].Subscriptions {
+		if stats.Group != "collectors" {
+			t.Errorf("Group = %q, want %q", stats.Group, "collectors")
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package subpub
+
+import "strings"
+
+// Субъекты иерархические: токены разделяются точкой, например "orders.eu.created".
+// В подписках допускаются подстановочные токены:
+//   - "*" совпадает ровно с одним токеном ("orders.*.created");
+//   - ">" совпадает с одним и более токенами в хвосте и может стоять только последним ("metrics.>").
+const (
+	tokenSeparator = "."
+	tokenWildcard  = "*"
+	tokenTail      = ">"
+)
+
+func splitSubject(subject string) []string {
+	return strings.Split(subject, tokenSeparator)
+}
+
+func joinTokens(tokens []string) string {
+	return strings.Join(tokens, tokenSeparator)
+}
+
+// validatePattern проверяет субъект подписки и возвращает его токены.
+func validatePattern(subject string) ([]string, error) {
+	if subject == "" {
+		return nil, ErrInvalidSubject
+	}
+	tokens := splitSubject(subject)
+	for i, token := range tokens {
+		switch {
+		case token == "":
+			return nil, ErrInvalidSubject
+		case token == tokenTail && i != len(tokens)-1:
+			return nil, ErrInvalidSubject
+		case token != tokenWildcard && token != tokenTail && strings.ContainsAny(token, tokenWildcard+tokenTail):
+			return nil, ErrInvalidSubject
+		}
+	}
+	return tokens, nil
+}
+
+// ValidateSubject проверяет, что в субъект можно публиковать сообщения.
+func ValidateSubject(subject string) error {
+	return validateSubject(subject)
+}
+
+// MatchSubject сообщает, подходит ли субъект публикации под субъект или шаблон подписки.
+func MatchSubject(pattern, subject string) bool {
+	return matchTokens(splitSubject(pattern), splitSubject(subject))
+}
+
+// validateSubject проверяет субъект публикации: подстановочные токены в нём запрещены.
+// В отличие от validatePattern, не разбивает субъект на токены и не выделяет память.
+func validateSubject(subject string) error {
+	if subject == "" {
+		return ErrInvalidSubject
+	}
+
+	var err error
+	start := 0
+	for i := 0; i <= len(subject); i++ {
+		if i < len(subject) && subject[i] != tokenSeparator[0] {
+			continue
+		}
+		token := subject[start:i]
+		switch {
+		case token == "":
+			return ErrInvalidSubject
+		case token == tokenTail && i != len(subject):
+			return ErrInvalidSubject
+		case token == tokenWildcard || token == tokenTail:
+			err = ErrWildcardSubject
+		case strings.ContainsAny(token, tokenWildcard+tokenTail):
+			return ErrInvalidSubject
+		}
+		start = i + 1
+	}
+	return err
+}
+
+func hasWildcard(tokens []string) bool {
+	for _, token := range tokens {
+		if token == tokenWildcard || token == tokenTail {
+			return true
+		}
+	}
+	return false
+}
+
+// matchTokens проверяет, подходит ли субъект под шаблон.
+func matchTokens(pattern, subject []string) bool {
+	for i, token := range pattern {
+		if token == tokenTail {
+			return len(subject) > i
+		}
+		if i >= len(subject) {
+			return false
+		}
+		if token != tokenWildcard && token != subject[i] {
+			return false
+		}
+	}
+	return len(pattern) == len(subject)
+}
@@ -0,0 +1,177 @@
+package subpub
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPatternMatching(t *testing.T) {
+	tests := []struct {
+		pattern string
+		subject string
+		want    bool
+	}{
+		{"orders.created", "orders.created", true},
+		{"orders.created", "orders.updated", false},
+		{"orders.*.created", "orders.eu.created", true},
+		{"orders.*.created", "orders.eu.us.created", false},
+		{"orders.*.created", "orders.created", false},
+		{"orders.*", "orders.eu", true},
+		{"orders.*", "orders", false},
+		{"metrics.>", "metrics.cpu", true},
+		{"metrics.>", "metrics.cpu.host1.load", true},
+		{"metrics.>", "metrics", false},
+		{">", "anything.at.all", true},
+		{"*.*", "a.b", true},
+		{"*.*", "a.b.c", false},
+		{"*.b.>", "a.b.c.d", true},
+		{"*.b.>", "a.c.c.d", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+"~"+tt.subject, func(t *testing.T) {
+			tokens, err := validatePattern(tt.pattern)
+			if err != nil {
+				t.Fatalf("validatePattern(%q) failed: %v", tt.pattern, err)
+			}
+			tr := newTrie()
+			sub := &subscription{}
+			tr.insert(tokens, sub)
+
+			got := len(tr.match(splitSubject(tt.subject), nil)) == 1
+			if got != tt.want {
+				t.Errorf("match(%q, %q) = %v, want %v", tt.pattern, tt.subject, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInvalidSubjects(t *testing.T) {
+	sp := NewSubPub()
+
+	for _, subject := range []string{"", ".", "a.", ".a", "a..b", "a.>.b", "a.b*", "a.>c"} {
+		if _, err := sp.Subscribe(subject, func(msg interface{}) {}); err != ErrInvalidSubject {
+			t.Errorf("Subscribe(%q): got %v, want %v", subject, err, ErrInvalidSubject)
+		}
+		if err := sp.Publish(subject, "msg"); err != ErrInvalidSubject {
+			t.Errorf("Publish(%q): got %v, want %v", subject, err, ErrInvalidSubject)
+		}
+	}
+
+	for _, subject := range []string{"*", "a.*", "a.>", ">"} {
+		if err := sp.Publish(subject, "msg"); err != ErrWildcardSubject {
+			t.Errorf("Publish(%q): got %v, want %v", subject, err, ErrWildcardSubject)
+		}
+	}
+}
+
+func TestOverlappingPatternsReceiveOneCopy(t *testing.T) {
+	sp := NewSubPub()
+
+	patterns := []string{"orders.eu.created", "orders.*.created", "orders.eu.*", "orders.>", ">"}
+	var mu sync.Mutex
+	counts := make(map[string]int)
+	var wg sync.WaitGroup
+	wg.Add(len(patterns))
+
+	for _, pattern := range patterns {
+		p := pattern
+		sub, err := sp.Subscribe(p, func(msg interface{}) {
+			mu.Lock()
+			counts[p]++
+			mu.Unlock()
+			wg.Done()
+		})
+		if err != nil {
+			t.Fatalf("Subscribe(%q) failed: %v", p, err)
+		}
+		defer sub.Unsubscribe()
+	}
+
+	if err := sp.Publish("orders.eu.created", "msg"); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for subscribers")
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, p := range patterns {
+		if counts[p] != 1 {
+			t.Errorf("pattern %q received %d copies, want 1", p, counts[p])
+		}
+	}
+}
+
+func TestWildcardUnsubscribe(t *testing.T) {
+	sp := NewSubPub()
+	received := make(chan interface{}, 1)
+
+	sub, err := sp.Subscribe("metrics.>", func(msg interface{}) {
+		received <- msg
+	})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	sub.Unsubscribe()
+
+	if err := sp.Publish("metrics.cpu", "msg"); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	select {
+	case <-received:
+		t.Error("received message after unsubscribe")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestTrieRemovePrunesNodes(t *testing.T) {
+	tr := newTrie()
+	subs := make([]*subscription, 0, 100)
+	for i := 0; i < 100; i++ {
+		sub := &subscription{tokens: splitSubject(fmt.Sprintf("a.%d.*.>", i))}
+		tr.insert(sub.tokens, sub)
+		subs = append(subs, sub)
+	}
+
+	for _, sub := range subs {
+		if ok, _ := tr.remove(sub.tokens, sub); !ok {
+			t.Fatalf("remove(%v) returned false", sub.tokens)
+		}
+	}
+
+	if tr.size != 0 {
+		t.Errorf("size = %d, want 0", tr.size)
+	}
+	if !tr.root.empty() {
+		t.Errorf("root still has %d children", len(tr.root.children))
+	}
+}
+
+func TestManyWildcardSubscriptions(t *testing.T) {
+	const subscriptionCount = 20000
+	tr := newTrie()
+	for i := 0; i < subscriptionCount; i++ {
+		tokens := splitSubject(fmt.Sprintf("orders.%d.*", i))
+		tr.insert(tokens, &subscription{tokens: tokens})
+	}
+	tokens := splitSubject("orders.*.created")
+	tr.insert(tokens, &subscription{tokens: tokens})
+
+	if got := len(tr.match(splitSubject("orders.42.created"), nil)); got != 2 {
+		t.Errorf("matched %d subscriptions, want 2", got)
+	}
+}
@@ -3,123 +3,380 @@ package subpub
 import (
 	"context"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type MessageHandler func(msg interface{})
 
-type Subscription interface {
-	Unsubscribe()
+// ContextHandler — обработчик, получающий контекст подписки. Контекст
+// отменяется при отписке и при закрытии шины, что позволяет долгим
+// обработчикам прервать работу.
+type ContextHandler func(ctx context.Context, msg interface{})
+
+type SubPub interface {
+	Subscribe(subject string, cb MessageHandler, opts ...SubscribeOption) (Subscription, error)
+	SubscribeQueue(subject, group string, cb MessageHandler, opts ...SubscribeOption) (Subscription, error)
+	SubscribeContext(subject string, cb ContextHandler, opts ...SubscribeOption) (Subscription, error)
+	SubscribeMsg(subject string, cb MsgHandler, opts ...SubscribeOption) (Subscription, error)
+	SubscribeRequests(subject string, cb RequestHandler, opts ...SubscribeOption) (Subscription, error)
+	SubscribeAck(subject string, cb AckHandler, opts ...SubscribeOption) (Subscription, error)
+	SubscribeRetry(subject string, cb RetryHandler, opts ...SubscribeOption) (Subscription, error)
+	SubscribeBatch(subject string, maxSize int, maxWait time.Duration, cb BatchHandler, opts ...SubscribeOption) (Subscription, error)
+	SubscribeChan(subject string, bufSize int, opts ...SubscribeOption) (<-chan interface{}, Subscription, error)
+	Publish(subject string, msg interface{}) error
+	PublishMsg(msg *Message) error
+	Request(ctx context.Context, subject string, msg interface{}) (interface{}, error)
+	RequestMany(ctx context.Context, subject string, msg interface{}, max int) ([]interface{}, error)
+	ClearRetained(subject string) error
+	Snapshot() []SubjectInfo
+	Scoped(prefix string) SubPub
+	Close(ctx context.Context) error
 }
 
-type subscription struct {
-	subject string
-	handler MessageHandler
-	done    chan struct{}
+type subPub struct {
+	opts options
+
+	// ctx отменяется, когда Close завершает доставку или истекает его ctx,
+	// и является родительским для контекстов подписок
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	routes *router
+	closed atomic.Bool
+
+	// wg учитывает горутины доставки всех подписок
+	wg      sync.WaitGroup
+	drained chan struct{}
+
+	inboxPrefix string
+	inboxSeq    atomic.Uint64
+
+	// retained не nil, если включено хранение сообщений
+	retained *retainedStore
+	ordinal  atomic.Uint64
+
+	// observer не nil, если подключены наблюдатели
+	observer Observer
+	// remote не nil у шины с транспортом
+	remote *remoteStreams
 }
 
-func (s *subscription) Unsubscribe() {
-	close(s.done)
+func NewSubPub(opts ...Option) SubPub {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.errorHook == nil {
+		o.errorHook = logErrors(o.logger)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sp := &subPub{
+		opts:        o,
+		ctx:         ctx,
+		cancel:      cancel,
+		routes:      newRouter(),
+		drained:     make(chan struct{}),
+		inboxPrefix: newInboxPrefix(),
+		observer:    newObserver(o.observers),
+	}
+	if o.retain > 0 && o.transport == nil {
+		sp.retained = newRetainedStore(o.retain)
+	}
+	if o.transport != nil {
+		sp.remote = newRemoteStreams(sp, o.transport)
+	}
+	return sp
 }
 
-type SubPub interface {
-	Subscribe(subject string, cb MessageHandler) (Subscription, error)
-	Publish(subject string, msg interface{}) error
-	Close(ctx context.Context) error
+// Subscribe подписывает обработчик на субъект или шаблон субъектов
+// с подстановочными токенами "*" и ">".
+func (sp *subPub) Subscribe(subject string, cb MessageHandler, opts ...SubscribeOption) (Subscription, error) {
+	return subscribed(sp.subscribe(subject, cb.deliver, opts))
 }
 
-type subPub struct {
-	mu          sync.RWMutex
-	subscribers map[string][]*subscription
-	closed      bool
+// SubscribeQueue подписывает обработчик в составе группы очередей: каждое
+// сообщение получает только один участник группы, тогда как обычные
+// подписчики того же субъекта продолжают получать все сообщения.
+func (sp *subPub) SubscribeQueue(subject, group string, cb MessageHandler, opts ...SubscribeOption) (Subscription, error) {
+	if group == "" {
+		return nil, ErrInvalidQueueGroup
+	}
+	return subscribed(sp.subscribe(subject, cb.deliver, append(opts[:len(opts):len(opts)], WithQueueGroup(group))))
 }
 
-func NewSubPub() SubPub {
-	return &subPub{
-		subscribers: make(map[string][]*subscription),
+// SubscribeContext подписывает обработчик, получающий контекст подписки.
+func (sp *subPub) SubscribeContext(subject string, cb ContextHandler, opts ...SubscribeOption) (Subscription, error) {
+	return subscribed(sp.subscribe(subject, cb.deliver, opts))
+}
+
+func (sp *subPub) subscribe(subject string, cb deliverFunc, opts []SubscribeOption) (*subscription, error) {
+	tokens, err := validatePattern(subject)
+	if err != nil {
+		return nil, err
+	}
+
+	o := sp.opts.subscribeDefaults()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.deadLetter != "" {
+		if err := validateSubject(o.deadLetter); err != nil {
+			return nil, err
+		}
+		// Недоставленное сообщение вернулось бы в ту же подписку и могло бы
+		// снова стать недоставленным, и так без конца
+		if matchTokens(tokens, splitSubject(o.deadLetter)) {
+			return nil, ErrDeadLetterLoop
+		}
+	}
+	if o.batch != nil && (o.maxInFlight > 1 || o.retryOptions) {
+		return nil, ErrBatchOption
 	}
+
+	wildcard := hasWildcard(tokens)
+	if sp.remote != nil {
+		if sp.remote.closing.Load() {
+			return nil, ErrClosed
+		}
+		if err := sp.remote.acquire(subject, tokens, wildcard); err != nil {
+			return nil, err
+		}
+	}
+
+	sub, err := sp.register(subject, tokens, wildcard, cb, o)
+	if err != nil {
+		if sp.remote != nil {
+			sp.remote.release(subject)
+		}
+		return nil, err
+	}
+	return sub, nil
 }
 
-func (sp *subPub) Subscribe(subject string, cb MessageHandler) (Subscription, error) {
-	sp.mu.Lock()
-	defer sp.mu.Unlock()
+// register создаёт подписку и добавляет её в список получателей.
+func (sp *subPub) register(subject string, tokens []string, wildcard bool, cb deliverFunc, o subscribeOptions) (*subscription, error) {
+	mu := sp.routes.lock(subject, wildcard)
+	mu.Lock()
+	defer mu.Unlock()
 
-	if sp.closed {
+	if sp.closed.Load() {
 		return nil, ErrClosed
 	}
 
-	sub := &subscription{
-		subject: subject,
-		handler: cb,
-		done:    make(chan struct{}),
+	sub := newSubscription(sp, subject, tokens, cb, o)
+	sub.wildcard = wildcard
+
+	// Наблюдатели узнают о подписке раньше, чем о любом событии её сообщений
+	if observer := sub.observer(); observer != nil {
+		observer.OnSubscribe(subject, o.group)
 	}
 
-	sp.subscribers[subject] = append(sp.subscribers[subject], sub)
+	// Сохранённые сообщения загружаются под той же блокировкой, под которой
+	// Publish сохраняет сообщение и выбирает получателей: каждое сообщение
+	// достаётся новой подписке либо из хранилища, либо из живого потока
+	if sp.retained != nil && o.group == "" {
+		sub.preload(sp.retained.match(tokens))
+	}
+
+	sp.wg.Add(1)
+	go sub.run()
+
+	sp.routes.insert(sub)
+	return sub, nil
+}
+
+// subscribed приводит результат subscribe к публичному интерфейсу,
+// не допуская ненулевого интерфейса с nil-указателем внутри.
+func subscribed(sub *subscription, err error) (Subscription, error) {
+	if err != nil {
+		return nil, err
+	}
 	return sub, nil
 }
 
+// Publish доставляет сообщение всем подпискам, чьи шаблоны совпадают с субъектом.
+// Субъект публикации не может содержать подстановочных токенов.
 func (sp *subPub) Publish(subject string, msg interface{}) error {
-	sp.mu.RLock()
-	if sp.closed {
-		sp.mu.RUnlock()
-		return ErrClosed
+	_, err := sp.publish(&envelope{subject: subject, msg: msg})
+	return err
+}
+
+// publish пропускает сообщение через перехватчики публикации, рассылает его
+// и возвращает число подписок, которым оно было адресовано, или -1, если
+// получателей выбирает внешний брокер.
+func (sp *subPub) publish(env *envelope) (int, error) {
+	interceptors := sp.opts.publishInterceptors
+	if len(interceptors) == 0 {
+		return sp.dispatch(env)
 	}
 
-	subscribers := make([]*subscription, len(sp.subscribers[subject]))
-	copy(subscribers, sp.subscribers[subject])
-	sp.mu.RUnlock()
+	var n int
+	err := chainPublish(interceptors, func(subject string, msg interface{}) error {
+		modified := *env
+		modified.subject, modified.msg = subject, msg
+		var err error
+		n, err = sp.dispatch(&modified)
+		return err
+	})(env.subject, env.msg)
+	return n, err
+}
 
+func (sp *subPub) dispatch(env *envelope) (int, error) {
+	if err := validateSubject(env.subject); err != nil {
+		return 0, err
+	}
+	env.published = time.Now().UnixNano()
+	if sp.remote != nil {
+		return sp.remote.publish(env)
+	}
+
+	buf := matchPool.Get().(*[]*subscription)
+	subscribers, err := sp.match(env, (*buf)[:0])
 	for _, sub := range subscribers {
-		select {
-		case <-sub.done:
-			continue
-		default:
-			go func(s *subscription) {
-				select {
-				case <-s.done:
-					return
-				default:
-					s.handler(msg)
-				}
-			}(sub)
+		sub.enqueue(env)
+	}
+	n := len(subscribers)
+	if sp.observer != nil && err == nil {
+		sp.observer.OnPublish(env.subject, n)
+	}
+
+	clear(subscribers)
+	*buf = subscribers[:0]
+	matchPool.Put(buf)
+	return n, err
+}
+
+// match добавляет к dst получателей сообщения и сохраняет его, если
+// включено хранение сообщений.
+func (sp *subPub) match(env *envelope, dst []*subscription) ([]*subscription, error) {
+	s := sp.routes.shard(env.subject)
+	s.mu.RLock()
+	if sp.closed.Load() {
+		s.mu.RUnlock()
+		return dst, ErrClosed
+	}
+	if sequenced(env) {
+		var prune bool
+		env.sequence, prune = s.nextSequence(env.subject)
+		if prune {
+			defer sp.routes.pruneSequences(s, sp.retained)
 		}
 	}
+	dst = sp.routes.matchExact(s, env.subject, dst)
 
-	return nil
+	// Сообщение сохраняется под блокировками и сегмента, и дерева шаблонов:
+	// под одной из них новая подписка загружает сохранённые сообщения
+	if sp.retained != nil && retainable(env) {
+		sp.routes.wmu.RLock()
+		env.ordinal = sp.ordinal.Add(1)
+		sp.retained.add(env)
+		dst = sp.routes.matchWildcards(env.subject, dst)
+		sp.routes.wmu.RUnlock()
+		s.mu.RUnlock()
+		return dst, nil
+	}
+	s.mu.RUnlock()
+
+	if sp.routes.hasWildcards() {
+		sp.routes.wmu.RLock()
+		dst = sp.routes.matchWildcards(env.subject, dst)
+		sp.routes.wmu.RUnlock()
+	}
+	return dst, nil
+}
+
+// remove исключает подписку из списка получателей. Для участника группы
+// очередей возвращает группу, если в ней остались другие участники.
+func (sp *subPub) remove(sub *subscription) *queueGroup {
+	mu := sp.routes.lock(sub.subject, sub.wildcard)
+	mu.Lock()
+	removed, group := sp.routes.remove(sub)
+	mu.Unlock()
+
+	if removed && sp.remote != nil {
+		sp.remote.release(sub.subject)
+	}
+	return group
 }
 
+// redistribute распределяет сообщения ушедшего участника между оставшимися
+// участниками группы. Получатели выбираются под блокировкой, а постановка
+// в очередь, которая может ждать места, выполняется уже без неё.
+func (sp *subPub) redistribute(gone *subscription, group *queueGroup, pending []*envelope) {
+	targets := make([]*subscription, 0, len(pending))
+
+	mu := sp.routes.lock(gone.subject, gone.wildcard)
+	mu.RLock()
+	if !sp.closed.Load() && len(group.members) > 0 {
+		for range pending {
+			targets = append(targets, group.pick())
+		}
+	}
+	mu.RUnlock()
+
+	for i, sub := range targets {
+		sub.enqueue(pending[i])
+	}
+}
+
+// Close прекращает приём публикаций и подписок, дожидается доставки уже
+// поставленных в очередь сообщений и завершения всех обработчиков.
+// Пока сообщения дочитываются, контексты подписок остаются действующими.
+// Если ctx истекает раньше, недоставленные сообщения отбрасываются,
+// контексты подписок отменяются, а Close возвращает ошибку контекста.
 func (sp *subPub) Close(ctx context.Context) error {
-	sp.mu.Lock()
-	if sp.closed {
-		sp.mu.Unlock()
-		return nil
+	// Подписки брокера сначала получают сообщения, опубликованные до закрытия
+	if sp.remote != nil {
+		sp.remote.close(ctx)
 	}
-	sp.closed = true
 
-	// Очищаем все подписки
-	for _, subs := range sp.subscribers {
+	sp.routes.lockAll()
+	var subs []*subscription
+	if !sp.closed.Load() {
+		sp.closed.Store(true)
+		subs = sp.routes.reset()
+
 		for _, sub := range subs {
-			select {
-			case <-sub.done:
-				// Канал уже закрыт, пропускаем
-				continue
-			default:
-				close(sub.done)
-			}
+			sub.drain()
 		}
+		go func() {
+			sp.wg.Wait()
+			close(sp.drained)
+		}()
 	}
-	sp.subscribers = make(map[string][]*subscription)
-	sp.mu.Unlock()
+	sp.routes.unlockAll()
 
-	// Ждем отмены контекста или таймаута
 	select {
+	case <-sp.drained:
+		sp.cancel()
+		return nil
 	case <-ctx.Done():
+		for _, sub := range subs {
+			sub.stop()
+		}
+		// Обработчики, ждущие отмены, завершаются, даже если подписки
+		// остановил не этот вызов Close
+		sp.cancel()
 		return ctx.Err()
 	}
 }
 
 // Custom errors
 var (
-	ErrClosed = &Error{"subpub: service is closed"}
+	ErrClosed            = &Error{"subpub: service is closed"}
+	ErrInvalidSubject    = &Error{"subpub: invalid subject"}
+	ErrWildcardSubject   = &Error{"subpub: cannot publish to a wildcard subject"}
+	ErrInvalidQueueGroup = &Error{"subpub: invalid queue group"}
+	ErrNoResponders      = &Error{"subpub: no responders"}
+	ErrNoReplySubject    = &Error{"subpub: message has no reply subject"}
+	ErrTooManyPanics     = &Error{"subpub: too many consecutive handler panics"}
+	ErrNacked            = &Error{"subpub: message was not acknowledged"}
+	ErrInvalidBatchSize  = &Error{"subpub: invalid batch size"}
+	ErrBatchOption       = &Error{"subpub: option is not supported by batch subscriptions"}
+	ErrRedeliveryStopped = &Error{"subpub: subscription stopped before the message was redelivered"}
+	ErrDeadLetterLoop    = &Error{"subpub: dead letter subject matches the subscription subject"}
 )
 
 type Error struct {
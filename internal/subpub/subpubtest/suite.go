@@ -0,0 +1,634 @@
+// Package subpubtest содержит тесты поведения, общие для всех реализаций
+// subpub.SubPub.
+package subpubtest
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"awesomeProject3/internal/subpub"
+)
+
+// BusFactory создаёт шину для тестов поведения. Шина, созданная через
+// фабрику, должна вести себя как шина NewSubPub с теми же параметрами.
+type BusFactory func(t *testing.T, opts ...subpub.Option) subpub.SubPub
+
+// suite — параметры запуска тестов поведения.
+type suite struct {
+	newBus BusFactory
+	// load — множитель числа сообщений в нагрузочных тестах
+	load float64
+	// slowdown — множитель ограничений по времени в нагрузочных тестах
+	slowdown float64
+}
+
+// SuiteOption настраивает RunBehaviourSuite.
+type SuiteOption func(*suite)
+
+// WithLoadFactor умножает на factor число сообщений в нагрузочных тестах
+// (DeliveryOrder, DeliveryOrderConcurrentSubjects и
+// SlowSubscriberDoesNotBlockPublish); остальные тесты от него не зависят.
+// Неположительные значения игнорируются.
+func WithLoadFactor(factor float64) SuiteOption {
+	return func(s *suite) {
+		if factor > 0 {
+			s.load = factor
+		}
+	}
+}
+
+// WithTimeFactor умножает на factor время, отведённое нагрузочным тестам
+// на публикацию и доставку сообщений. Пригодится шинам, у которых каждая
+// публикация — сетевой вызов, при запуске с детектором гонок.
+// Неположительные значения игнорируются.
+func WithTimeFactor(factor float64) SuiteOption {
+	return func(s *suite) {
+		if factor > 0 {
+			s.slowdown = factor
+		}
+	}
+}
+
+func newSuite(newBus BusFactory, opts ...SuiteOption) *suite {
+	s := &suite{newBus: newBus, load: 1, slowdown: 1}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// messages возвращает число сообщений нагрузочного теста с учётом load.
+func (s *suite) messages(n int) int {
+	return max(int(float64(n)*s.load), 1)
+}
+
+// timeout возвращает ограничение по времени с учётом slowdown.
+func (s *suite) timeout(d time.Duration) time.Duration {
+	return time.Duration(float64(d) * s.slowdown)
+}
+
+// behaviourSuite — тесты поведения, общие для всех реализаций шины.
+var behaviourSuite = []struct {
+	name string
+	run  func(t *testing.T, s *suite)
+}{
+	{"NewSubPub", testNewSubPub},
+	{"SubscribeAndPublish", testSubscribeAndPublish},
+	{"MultipleSubscribers", testMultipleSubscribers},
+	{"Unsubscribe", testUnsubscribe},
+	{"Close", testClose},
+	{"SlowSubscriber", testSlowSubscriber},
+	{"DeliveryOrder", testDeliveryOrder},
+	{"DeliveryOrderConcurrentSubjects", testDeliveryOrderConcurrentSubjects},
+	{"SlowSubscriberDoesNotBlockPublish", testSlowSubscriberDoesNotBlockPublish},
+	{"QueueOverflowDropsNewest", testQueueOverflowDropsNewest},
+	{"CloseDrainsQueuedMessages", testCloseDrainsQueuedMessages},
+	{"CloseDeadline", testCloseDeadline},
+}
+
+// RunBehaviourSuite проверяет шину, созданную newBus, тестами поведения
+// шины NewSubPub.
+func RunBehaviourSuite(t *testing.T, newBus BusFactory, opts ...SuiteOption) {
+	s := newSuite(newBus, opts...)
+	for _, tc := range behaviourSuite {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			tc.run(t, s)
+		})
+	}
+}
+
+func testNewSubPub(t *testing.T, s *suite) {
+	sp := s.newBus(t)
+	if sp == nil {
+		t.Error("NewSubPub returned nil")
+	}
+}
+
+func testSubscribeAndPublish(t *testing.T, s *suite) {
+	sp := s.newBus(t)
+	received := make(chan interface{}, 1)
+
+	sub, err := sp.Subscribe("test", func(msg interface{}) {
+		received <- msg
+	})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	msg := "test message"
+	err = sp.Publish("test", msg)
+	if err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	select {
+	case got := <-received:
+		if got != msg {
+			t.Errorf("got %v, want %v", got, msg)
+		}
+	case <-time.After(time.Second):
+		t.Error("timeout waiting for message")
+	}
+
+	sub.Unsubscribe()
+}
+
+func testMultipleSubscribers(t *testing.T, s *suite) {
+	sp := s.newBus(t)
+	var wg sync.WaitGroup
+	subscriberCount := 3
+	received := make([]chan interface{}, subscriberCount)
+
+	for i := 0; i < subscriberCount; i++ {
+		received[i] = make(chan interface{}, 1)
+		wg.Add(1)
+		idx := i
+		sub, err := sp.Subscribe("test", func(msg interface{}) {
+			received[idx] <- msg
+			wg.Done()
+		})
+		if err != nil {
+			t.Fatalf("Subscribe failed: %v", err)
+		}
+		defer sub.Unsubscribe()
+	}
+
+	msg := "test message"
+	err := sp.Publish("test", msg)
+	if err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		// All subscribers received the message
+		for i := 0; i < subscriberCount; i++ {
+			select {
+			case got := <-received[i]:
+				if got != msg {
+					t.Errorf("subscriber %d got %v, want %v", i, got, msg)
+				}
+			case <-time.After(time.Second):
+				t.Errorf("timeout waiting for subscriber %d", i)
+			}
+		}
+	case <-time.After(time.Second):
+		t.Error("timeout waiting for all subscribers")
+	}
+}
+
+func testUnsubscribe(t *testing.T, s *suite) {
+	sp := s.newBus(t)
+	received := make(chan interface{}, 1)
+
+	sub, err := sp.Subscribe("test", func(msg interface{}) {
+		received <- msg
+	})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	sub.Unsubscribe()
+
+	err = sp.Publish("test", "test message")
+	if err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	select {
+	case <-received:
+		t.Error("received message after unsubscribe")
+	case <-time.After(100 * time.Millisecond):
+		// Expected timeout - no message should be received
+	}
+}
+
+func testClose(t *testing.T, s *suite) {
+	sp := s.newBus(t)
+
+	// Создаем подписку перед закрытием
+	received := make(chan interface{}, 1)
+	_, err := sp.Subscribe("test", func(msg interface{}) {
+		received <- msg
+	})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	// Проверяем, что подписка работает до закрытия
+	err = sp.Publish("test", "test message")
+	if err != nil {
+		t.Errorf("Publish before close failed: %v", err)
+	}
+
+	select {
+	case msg := <-received:
+		if msg != "test message" {
+			t.Errorf("received wrong message: got %v, want %v", msg, "test message")
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Error("timeout waiting for message before close")
+	}
+
+	// Создаем контекст с таймаутом
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// Закрываем сервис: ожидающих сообщений нет, Close завершается сразу
+	err = sp.Close(ctx)
+	if err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+
+	// Проверяем повторное закрытие
+	err = sp.Close(ctx)
+	if err != nil {
+		t.Errorf("Second close failed: %v", err)
+	}
+
+	// Проверяем, что подписка больше не работает
+	err = sp.Publish("test", "test message")
+	if err != subpub.ErrClosed {
+		t.Errorf("Publish after close: got %v, want %v", err, subpub.ErrClosed)
+	}
+
+	select {
+	case <-received:
+		t.Error("received message after close")
+	case <-time.After(100 * time.Millisecond):
+		// Ожидаемый таймаут - сообщение не должно быть получено
+	}
+
+	// Пробуем создать новую подписку после закрытия
+	_, err = sp.Subscribe("test", func(msg interface{}) {})
+	if err != subpub.ErrClosed {
+		t.Errorf("Subscribe after close: got %v, want %v", err, subpub.ErrClosed)
+	}
+}
+
+func testSlowSubscriber(t *testing.T, s *suite) {
+	sp := s.newBus(t)
+	var wg sync.WaitGroup
+	fastReceived := make(chan interface{}, 1)
+	slowReceived := make(chan interface{}, 1)
+
+	// Fast subscriber
+	wg.Add(1)
+	fastSub, err := sp.Subscribe("test", func(msg interface{}) {
+		fastReceived <- msg
+		wg.Done()
+	})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer fastSub.Unsubscribe()
+
+	// Slow subscriber
+	wg.Add(1)
+	slowSub, err := sp.Subscribe("test", func(msg interface{}) {
+		time.Sleep(500 * time.Millisecond)
+		slowReceived <- msg
+		wg.Done()
+	})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer slowSub.Unsubscribe()
+
+	msg := "test message"
+	err = sp.Publish("test", msg)
+	if err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	// Fast subscriber should receive message quickly
+	select {
+	case got := <-fastReceived:
+		if got != msg {
+			t.Errorf("fast subscriber got %v, want %v", got, msg)
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Error("timeout waiting for fast subscriber")
+	}
+
+	// Slow subscriber should still receive message
+	select {
+	case got := <-slowReceived:
+		if got != msg {
+			t.Errorf("slow subscriber got %v, want %v", got, msg)
+		}
+	case <-time.After(time.Second):
+		t.Error("timeout waiting for slow subscriber")
+	}
+
+	wg.Wait()
+}
+
+func testDeliveryOrder(t *testing.T, s *suite) {
+	const subscriberCount = 5
+	messageCount := s.messages(5000)
+	sp := s.newBus(t, subpub.WithQueueSize(messageCount))
+
+	var wg sync.WaitGroup
+	received := make([][]int, subscriberCount)
+	for i := 0; i < subscriberCount; i++ {
+		idx := i
+		wg.Add(messageCount)
+		sub, err := sp.Subscribe("test", func(msg interface{}) {
+			received[idx] = append(received[idx], msg.(int))
+			wg.Done()
+		})
+		if err != nil {
+			t.Fatalf("Subscribe failed: %v", err)
+		}
+		defer sub.Unsubscribe()
+	}
+
+	for i := 0; i < messageCount; i++ {
+		if err := sp.Publish("test", i); err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(s.timeout(5 * time.Second)):
+		t.Fatal("timeout waiting for all messages")
+	}
+
+	for i, msgs := range received {
+		for want, got := range msgs {
+			if got != want {
+				t.Fatalf("subscriber %d: message %d out of order: got %d", i, want, got)
+			}
+		}
+	}
+}
+
+func testDeliveryOrderConcurrentSubjects(t *testing.T, s *suite) {
+	const subjectCount = 4
+	messageCount := s.messages(2000)
+	sp := s.newBus(t, subpub.WithQueueSize(messageCount))
+
+	var wg sync.WaitGroup
+	received := make([][]int, subjectCount)
+	for i := 0; i < subjectCount; i++ {
+		idx := i
+		wg.Add(messageCount)
+		sub, err := sp.Subscribe(fmt.Sprintf("subject-%d", i), func(msg interface{}) {
+			received[idx] = append(received[idx], msg.(int))
+			wg.Done()
+		})
+		if err != nil {
+			t.Fatalf("Subscribe failed: %v", err)
+		}
+		defer sub.Unsubscribe()
+	}
+
+	// Каждый субъект публикуется своим издателем параллельно с остальными.
+	// Издатели завершаются до отписки и закрытия шины
+	var publishers sync.WaitGroup
+	defer publishers.Wait()
+	for i := 0; i < subjectCount; i++ {
+		publishers.Add(1)
+		go func(subject string) {
+			defer publishers.Done()
+			for n := 0; n < messageCount; n++ {
+				if err := sp.Publish(subject, n); err != nil {
+					t.Errorf("Publish failed: %v", err)
+					return
+				}
+			}
+		}(fmt.Sprintf("subject-%d", i))
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(s.timeout(5 * time.Second)):
+		t.Fatal("timeout waiting for all messages")
+	}
+
+	for i, msgs := range received {
+		for want, got := range msgs {
+			if got != want {
+				t.Fatalf("subject %d: message %d out of order: got %d", i, want, got)
+			}
+		}
+	}
+}
+
+func testSlowSubscriberDoesNotBlockPublish(t *testing.T, s *suite) {
+	messageCount := s.messages(1000)
+	sp := s.newBus(t, subpub.WithQueueSize(messageCount))
+
+	release := make(chan struct{})
+	slowSub, err := sp.Subscribe("test", func(msg interface{}) {
+		<-release
+	})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer slowSub.Unsubscribe()
+	defer close(release)
+
+	var fastCount int
+	fastDone := make(chan struct{})
+	fastSub, err := sp.Subscribe("test", func(msg interface{}) {
+		fastCount++
+		if fastCount == messageCount {
+			close(fastDone)
+		}
+	})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer fastSub.Unsubscribe()
+
+	goroutines := runtime.NumGoroutine()
+
+	start := time.Now()
+	for i := 0; i < messageCount; i++ {
+		if err := sp.Publish("test", i); err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > s.timeout(time.Second) {
+		t.Errorf("Publish took %v with a blocked subscriber", elapsed)
+	}
+
+	select {
+	case <-fastDone:
+	case <-time.After(s.timeout(time.Second)):
+		t.Fatal("fast subscriber was delayed by the slow one")
+	}
+
+	// Доставка не порождает горутину на каждое сообщение
+	if n := runtime.NumGoroutine(); n > goroutines+2 {
+		t.Errorf("goroutine count grew from %d to %d", goroutines, n)
+	}
+}
+
+func testQueueOverflowDropsNewest(t *testing.T, s *suite) {
+	sp := s.newBus(t, subpub.WithQueueSize(2))
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	received := make(chan interface{}, 10)
+	sub, err := sp.Subscribe("test", func(msg interface{}) {
+		if msg == 0 {
+			close(started)
+			<-release
+		}
+		received <- msg
+	})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	sp.Publish("test", 0)
+	<-started
+
+	// Обработчик занят сообщением 0, очередь вмещает только 1 и 2
+	for i := 1; i <= 4; i++ {
+		sp.Publish("test", i)
+	}
+	// У шины с транспортом сообщение попадает в очередь подписки уже после
+	// возврата из Publish, поэтому ждём, пока 3 и 4 будут отброшены
+	deadline := time.Now().Add(s.timeout(time.Second))
+	for sub.Stats().Dropped < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("stats = %+v, want 2 dropped", sub.Stats())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+
+	for _, want := range []int{0, 1, 2} {
+		select {
+		case got := <-received:
+			if got != want {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for message %d", want)
+		}
+	}
+
+	select {
+	case got := <-received:
+		t.Errorf("received overflowed message %v", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func testCloseDrainsQueuedMessages(t *testing.T, s *suite) {
+	const messageCount = 100
+	sp := s.newBus(t)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var received []int
+	_, err := sp.Subscribe("test", func(msg interface{}) {
+		if msg == 0 {
+			close(started)
+			<-release
+		}
+		received = append(received, msg.(int))
+	})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	for i := 0; i < messageCount; i++ {
+		if err := sp.Publish("test", i); err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+	}
+	<-started
+
+	closed := make(chan error, 1)
+	go func() {
+		closed <- sp.Close(context.Background())
+	}()
+
+	select {
+	case err := <-closed:
+		t.Fatalf("Close returned %v while a handler was still running", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := sp.Publish("test", messageCount); err != subpub.ErrClosed {
+		t.Errorf("Publish during close: got %v, want %v", err, subpub.ErrClosed)
+	}
+
+	close(release)
+
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for Close")
+	}
+
+	if len(received) != messageCount {
+		t.Fatalf("received %d messages, want %d", len(received), messageCount)
+	}
+	for want, got := range received {
+		if got != want {
+			t.Fatalf("message %d out of order: got %d", want, got)
+		}
+	}
+}
+
+func testCloseDeadline(t *testing.T, s *suite) {
+	sp := s.newBus(t)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	_, err := sp.Subscribe("test", func(msg interface{}) {
+		close(started)
+		<-release
+	})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	if err := sp.Publish("test", "test message"); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := sp.Close(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Close error: got %v, want %v", err, context.DeadlineExceeded)
+	}
+}
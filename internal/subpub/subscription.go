@@ -0,0 +1,377 @@
+package subpub
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Subscription interface {
+	// Unsubscribe прекращает доставку и отменяет контекст подписки,
+	// не дожидаясь завершения выполняющегося обработчика.
+	Unsubscribe()
+	// UnsubscribeWait отписывается и ждёт, пока выполняющийся обработчик
+	// вернёт управление, но не дольше, чем до отмены ctx. Не должен
+	// вызываться из обработчика самой подписки.
+	UnsubscribeWait(ctx context.Context) error
+	// Stats возвращает текущую статистику подписки.
+	Stats() SubscriptionStats
+	// Pause приостанавливает доставку, сохраняя накопленные сообщения.
+	Pause()
+	// Resume возобновляет доставку после Pause.
+	Resume()
+}
+
+// subscription владеет ограниченной очередью сообщений, которую разбирает
+// единственная горутина доставки. Благодаря этому порядок доставки
+// совпадает с порядком публикации, а медленный обработчик не задерживает
+// ни Publish, ни других подписчиков.
+type subscription struct {
+	bus     *subPub
+	subject string
+	tokens  []string
+	group   string
+	// wildcard — шаблон содержит подстановочные токены
+	wildcard bool
+	// index — позиция в наборе подписок субъекта, изменяется под его блокировкой
+	index   int
+	handler deliverFunc
+	opts    subscribeOptions
+
+	mu       sync.Mutex
+	queue    *queue[*envelope]
+	dropped  atomic.Uint64
+	filtered atomic.Uint64
+
+	delivered    atomic.Uint64
+	lastDelivery atomic.Int64
+
+	panicked          atomic.Uint64
+	consecutivePanics atomic.Int64
+
+	redelivered  atomic.Uint64
+	deadLettered atomic.Uint64
+
+	// limiter не nil, если задано ограничение скорости доставки
+	limiter *tokenBucket
+	// slots не nil, если разрешены параллельные вызовы обработчика
+	slots    chan struct{}
+	handlers sync.WaitGroup
+	inFlight atomic.Int64
+
+	// ctx отменяется при отписке и при закрытии шины
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	paused  atomic.Bool
+	resumed chan struct{}
+
+	ready     chan struct{}
+	space     chan struct{}
+	draining  chan struct{}
+	done      chan struct{}
+	finished  chan struct{}
+	drainOnce sync.Once
+	stopOnce  sync.Once
+}
+
+func newSubscription(bus *subPub, subject string, tokens []string, cb deliverFunc, opts subscribeOptions) *subscription {
+	ctx, cancel := context.WithCancel(bus.ctx)
+	s := &subscription{
+		bus:      bus,
+		subject:  subject,
+		tokens:   tokens,
+		group:    opts.group,
+		handler:  cb,
+		opts:     opts,
+		queue:    newQueue[*envelope](opts.queueSize),
+		resumed:  make(chan struct{}, 1),
+		ready:    make(chan struct{}, 1),
+		space:    make(chan struct{}, 1),
+		draining: make(chan struct{}),
+		done:     make(chan struct{}),
+		finished: make(chan struct{}),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	if opts.out != nil {
+		s.handler = s.send
+	}
+	if opts.rate > 0 {
+		s.limiter = newTokenBucket(opts.rate, opts.burst)
+	}
+	if opts.maxInFlight > 1 {
+		s.slots = make(chan struct{}, opts.maxInFlight)
+	}
+	return s
+}
+
+func (s *subscription) Unsubscribe() {
+	group := s.bus.remove(s)
+	pending := s.stop()
+
+	// Недоставленные сообщения участника группы передаются оставшимся участникам
+	if group != nil && len(pending) > 0 {
+		s.bus.redistribute(s, group, pending)
+	}
+}
+
+func (s *subscription) UnsubscribeWait(ctx context.Context) error {
+	s.Unsubscribe()
+
+	select {
+	case <-s.finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// disconnect принудительно отписывает подписчика, не справляющегося с потоком сообщений.
+func (s *subscription) disconnect() {
+	group := s.bus.remove(s)
+	pending := s.stop()
+
+	if group != nil && len(pending) > 0 {
+		s.bus.redistribute(s, group, pending)
+		return
+	}
+	for _, env := range pending {
+		s.drop(env, DropDisconnect)
+	}
+}
+
+// stop останавливает доставку и возвращает ещё не доставленные сообщения.
+func (s *subscription) stop() []*envelope {
+	var pending []*envelope
+	s.stopOnce.Do(func() {
+		close(s.done)
+		s.cancel()
+		// Пакетная подписка сама доставляет оставшиеся сообщения при завершении
+		if s.opts.batch != nil {
+			return
+		}
+		s.mu.Lock()
+		for s.queue.len() > 0 {
+			env, _ := s.queue.pop()
+			pending = append(pending, env)
+		}
+		s.mu.Unlock()
+	})
+	return pending
+}
+
+// drain запрещает приём новых сообщений; горутина доставки завершится,
+// как только доставит всё, что уже находится в очереди.
+func (s *subscription) drain() {
+	s.drainOnce.Do(func() {
+		s.mu.Lock()
+		close(s.draining)
+		s.mu.Unlock()
+	})
+}
+
+// preload помещает в пустую очередь сохранённые сообщения до того, как
+// подписка станет видна издателям. Если их больше ёмкости очереди,
+// воспроизводятся только самые новые.
+func (s *subscription) preload(envs []*envelope) {
+	if len(s.opts.filters) > 0 {
+		accepted := envs[:0]
+		for _, env := range envs {
+			if s.accepts(env) {
+				accepted = append(accepted, env)
+			}
+		}
+		envs = accepted
+	}
+	if n := s.opts.queueSize; len(envs) > n {
+		envs = envs[len(envs)-n:]
+	}
+
+	s.mu.Lock()
+	for _, env := range envs {
+		s.queue.push(env)
+	}
+	s.mu.Unlock()
+
+	for _, env := range envs {
+		s.observeEnqueue(env)
+	}
+
+	if len(envs) > 0 {
+		notify(s.ready)
+	}
+}
+
+// enqueue ставит сообщение в очередь подписчика, если его принимают фильтры
+// подписки. При заполненной очереди поведение определяется политикой
+// переполнения подписки; ждать места издатель может только при политике Block.
+func (s *subscription) enqueue(env *envelope) bool {
+	if !s.accepts(env) {
+		return false
+	}
+
+	var timeout *time.Timer
+	for {
+		s.mu.Lock()
+		if isClosed(s.done) || isClosed(s.draining) {
+			s.mu.Unlock()
+			return false
+		}
+		if s.queue.push(env) {
+			s.mu.Unlock()
+			notify(s.ready)
+			s.observeEnqueue(env)
+			return true
+		}
+
+		switch s.opts.policy {
+		case DropOldest:
+			oldest, _ := s.queue.pop()
+			s.queue.push(env)
+			s.mu.Unlock()
+			notify(s.ready)
+			s.observeEnqueue(env)
+			s.drop(oldest, DropOverflow)
+			return true
+
+		case Block:
+			s.mu.Unlock()
+			if timeout == nil {
+				timeout = time.NewTimer(s.opts.blockTimeout)
+				defer timeout.Stop()
+			}
+			select {
+			case <-s.space:
+				continue
+			case <-timeout.C:
+				s.drop(env, DropTimeout)
+				return false
+			case <-s.done:
+				return false
+			case <-s.draining:
+				return false
+			}
+
+		case Disconnect:
+			s.mu.Unlock()
+			s.drop(env, DropDisconnect)
+			s.disconnect()
+			return false
+
+		default:
+			s.mu.Unlock()
+			s.drop(env, DropOverflow)
+			return false
+		}
+	}
+}
+
+// drop учитывает отброшенное сообщение и сообщает о нём обработчику шины
+// и наблюдателям.
+func (s *subscription) drop(env *envelope, reason DropReason) {
+	s.dropped.Add(1)
+	if h := s.bus.opts.dropHandler; h != nil {
+		h(s.subject, env.msg, s.opts.policy)
+	}
+	if h := s.opts.onDrop; h != nil {
+		h(s.subject, env.msg, s.opts.policy)
+	}
+	s.observeDrop(env, reason)
+}
+
+// pending возвращает число сообщений, ожидающих доставки.
+func (s *subscription) pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.queue.len()
+}
+
+func (s *subscription) dequeue() (*envelope, bool) {
+	s.mu.Lock()
+	env, ok := s.queue.pop()
+	s.mu.Unlock()
+
+	if ok {
+		notify(s.space)
+	}
+	return env, ok
+}
+
+// run — цикл доставки, выполняется в отдельной горутине на всё время жизни подписки.
+// Подписка считается завершённой, когда вернули управление и все
+// параллельно выполняющиеся обработчики.
+func (s *subscription) run() {
+	defer s.bus.wg.Done()
+	defer close(s.finished)
+	defer s.observeUnsubscribe()
+	defer s.cancel()
+	defer s.closeOut()
+	defer s.handlers.Wait()
+
+	if s.opts.batch != nil {
+		s.runBatches()
+		return
+	}
+
+	for {
+		if !s.waitResumed() {
+			return
+		}
+		select {
+		case <-s.done:
+			return
+		case <-s.ready:
+		case <-s.draining:
+		}
+
+		// После drain очередь больше не пополняется, поэтому состояние
+		// фиксируется до её опустошения
+		draining := isClosed(s.draining)
+
+		for !s.paused.Load() {
+			select {
+			case <-s.done:
+				return
+			default:
+			}
+
+			// Разрешение запрашивается, только когда есть что доставлять,
+			// а до его получения сообщения остаются в очереди
+			if s.pending() == 0 {
+				break
+			}
+			if !s.acquire() {
+				return
+			}
+			env, ok := s.dequeue()
+			if !ok {
+				s.release()
+				break
+			}
+			s.dispatch(env)
+		}
+
+		if draining && s.pending() == 0 {
+			return
+		}
+	}
+}
+
+// notify неблокирующе сигнализирует в канал ёмкостью 1.
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
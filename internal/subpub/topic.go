@@ -0,0 +1,49 @@
+package subpub
+
+// TypedHandler — обработчик типизированных сообщений Topic[T].
+type TypedHandler[T any] func(msg T)
+
+// Topic — типизированное представление субъекта поверх нетипизированной шины.
+// Тип полезной нагрузки проверяется при компиляции, а сами сообщения
+// публикуются в тот же субъект, поэтому Topic[T] и обычные подписки SubPub
+// могут использовать его совместно.
+type Topic[T any] struct {
+	bus     SubPub
+	subject string
+}
+
+// NewTopic создаёт типизированный субъект на шине sp.
+func NewTopic[T any](sp SubPub, subject string) *Topic[T] {
+	return &Topic[T]{
+		bus:     sp,
+		subject: subject,
+	}
+}
+
+// Subject возвращает субъект, с которым связан Topic.
+func (t *Topic[T]) Subject() string {
+	return t.subject
+}
+
+func (t *Topic[T]) Publish(msg T) error {
+	return t.bus.Publish(t.subject, msg)
+}
+
+// Subscribe подписывает типизированный обработчик. Сообщения другого типа,
+// опубликованные в субъект через нетипизированный SubPub, пропускаются.
+func (t *Topic[T]) Subscribe(cb TypedHandler[T], opts ...SubscribeOption) (Subscription, error) {
+	return t.bus.Subscribe(t.subject, typed(cb), opts...)
+}
+
+// SubscribeQueue подписывает типизированный обработчик в составе группы очередей.
+func (t *Topic[T]) SubscribeQueue(group string, cb TypedHandler[T], opts ...SubscribeOption) (Subscription, error) {
+	return t.bus.SubscribeQueue(t.subject, group, typed(cb), opts...)
+}
+
+func typed[T any](cb TypedHandler[T]) MessageHandler {
+	return func(msg interface{}) {
+		if v, ok := msg.(T); ok {
+			cb(v)
+		}
+	}
+}
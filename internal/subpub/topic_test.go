@@ -0,0 +1,132 @@
+package subpub
+
+import (
+	"testing"
+	"time"
+)
+
+type orderCreated struct {
+	ID    int
+	Total float64
+}
+
+func TestTopicPublishSubscribe(t *testing.T) {
+	sp := NewSubPub()
+	orders := NewTopic[orderCreated](sp, "orders.created")
+
+	received := make(chan orderCreated, 1)
+	sub, err := orders.Subscribe(func(msg orderCreated) {
+		received <- msg
+	})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	want := orderCreated{ID: 1, Total: 9.99}
+	if err := orders.Publish(want); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	select {
+	case got := <-received:
+		if got != want {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for message")
+	}
+}
+
+func TestTopicSharesSubjectWithUntypedBus(t *testing.T) {
+	sp := NewSubPub()
+	orders := NewTopic[orderCreated](sp, "orders.created")
+
+	typedReceived := make(chan orderCreated, 2)
+	typedSub, err := orders.Subscribe(func(msg orderCreated) {
+		typedReceived <- msg
+	})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer typedSub.Unsubscribe()
+
+	untypedReceived := make(chan interface{}, 2)
+	untypedSub, err := sp.Subscribe("orders.*", func(msg interface{}) {
+		untypedReceived <- msg
+	})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer untypedSub.Unsubscribe()
+
+	// Сообщение другого типа не должно доходить до типизированного подписчика
+	if err := sp.Publish("orders.created", "not an order"); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	want := orderCreated{ID: 2}
+	if err := orders.Publish(want); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	select {
+	case got := <-typedReceived:
+		if got != want {
+			t.Errorf("typed subscriber got %+v, want %+v", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for typed message")
+	}
+
+	for _, w := range []interface{}{"not an order", want} {
+		select {
+		case got := <-untypedReceived:
+			if got != w {
+				t.Errorf("untyped subscriber got %v, want %v", got, w)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for untyped message")
+		}
+	}
+
+	select {
+	case got := <-typedReceived:
+		t.Errorf("typed subscriber received unexpected %+v", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestTopicSubscribeQueue(t *testing.T) {
+	sp := NewSubPub()
+	jobs := NewTopic[int](sp, "jobs")
+
+	received := make(chan int, 10)
+	for i := 0; i < 2; i++ {
+		sub, err := jobs.SubscribeQueue("workers", func(msg int) {
+			received <- msg
+		})
+		if err != nil {
+			t.Fatalf("SubscribeQueue failed: %v", err)
+		}
+		defer sub.Unsubscribe()
+	}
+
+	if err := jobs.Publish(42); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	select {
+	case got := <-received:
+		if got != 42 {
+			t.Errorf("got %d, want 42", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for message")
+	}
+
+	select {
+	case got := <-received:
+		t.Errorf("message delivered twice: %d", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,183 @@
+package subpub
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Transport связывает шину с внешним брокером сообщений, например с сервисом
+// PubSub. Шина с транспортом отправляет публикации брокеру, а не своим
+// подписчикам напрямую, и получает сообщения для своих подписок из подписок
+// брокера: на каждый субъект или шаблон открывается одна подписка брокера,
+// общая для всех подписок шины на него. Очереди, политики переполнения,
+// фильтры, группы очередей (в пределах шины) и остальные возможности
+// подписок работают так же, как без транспорта.
+type Transport interface {
+	// Publish отправляет сообщение брокеру. Номер сообщения шина не
+	// назначает: Sequence равен 0. reply — субъект для ответа, пустой для
+	// обычной публикации.
+	Publish(msg *Message, reply string) error
+	// Subscribe открывает у брокера подписку на субъект или шаблон и
+	// возвращает управление, когда брокер начал направлять в неё сообщения.
+	// Полученные сообщения передаются в deliver последовательно, в порядке
+	// получения. Подписка действует до вызова возвращённой функции отмены.
+	// Отмена не ждёт завершения deliver и может вызываться из него.
+	Subscribe(pattern string, deliver TransportHandler) (cancel func(), err error)
+	// Close вызывается при закрытии шины, когда публикации уже прекращены.
+	// Транспорт дожидается, пока открытые подписки получат сообщения,
+	// опубликованные через него до закрытия, но не дольше ctx, после чего
+	// отменяет все подписки.
+	Close(ctx context.Context) error
+}
+
+// TransportHandler получает сообщение из подписки брокера. Пустой Subject
+// означает, что брокер не сообщил субъект публикации, а нулевой Timestamp —
+// что неизвестно время публикации: тогда им считается время получения.
+type TransportHandler func(msg *Message, reply string)
+
+// WithTransport подключает шину к внешнему брокеру. Сохранение сообщений
+// (WithRetainedMessages) для такой шины не действует, а Request не может
+// определить отсутствие получателей и ждёт ответа до отмены контекста.
+func WithTransport(t Transport) Option {
+	return func(o *options) {
+		o.transport = t
+	}
+}
+
+// remoteStreams — подписки брокера, открытые шиной с транспортом.
+type remoteStreams struct {
+	bus       *subPub
+	transport Transport
+	// closing выставляется в начале Close, до ожидания подписок брокера
+	closing atomic.Bool
+
+	mu      sync.Mutex
+	streams map[string]*remoteStream
+}
+
+// remoteStream — подписка брокера на шаблон, общая для подписок шины на него.
+type remoteStream struct {
+	refs   int
+	ready  chan struct{}
+	err    error
+	cancel func()
+	// released выставляется перед отменой: сообщения, полученные после
+	// неё, не доставляются новым подпискам на тот же шаблон
+	released atomic.Bool
+}
+
+func newRemoteStreams(bus *subPub, transport Transport) *remoteStreams {
+	return &remoteStreams{
+		bus:       bus,
+		transport: transport,
+		streams:   make(map[string]*remoteStream),
+	}
+}
+
+// acquire открывает подписку брокера на шаблон или присоединяется к уже
+// открытой. Каждому успешному acquire соответствует один release.
+func (r *remoteStreams) acquire(pattern string, tokens []string, wildcard bool) error {
+	r.mu.Lock()
+	if s, ok := r.streams[pattern]; ok {
+		s.refs++
+		r.mu.Unlock()
+		<-s.ready
+		return s.err
+	}
+	s := &remoteStream{refs: 1, ready: make(chan struct{})}
+	r.streams[pattern] = s
+	r.mu.Unlock()
+
+	s.cancel, s.err = r.transport.Subscribe(pattern, r.deliver(s, pattern, tokens, wildcard))
+	if s.err != nil {
+		r.mu.Lock()
+		delete(r.streams, pattern)
+		r.mu.Unlock()
+	}
+	close(s.ready)
+	return s.err
+}
+
+// release отменяет подписку брокера, когда на шаблон не остаётся подписок шины.
+// Не вызывается под блокировками маршрутизатора.
+func (r *remoteStreams) release(pattern string) {
+	r.mu.Lock()
+	s := r.streams[pattern]
+	s.refs--
+	if s.refs > 0 {
+		r.mu.Unlock()
+		return
+	}
+	delete(r.streams, pattern)
+	r.mu.Unlock()
+
+	s.released.Store(true)
+	s.cancel()
+}
+
+// deliver ставит сообщение из подписки брокера в очереди подписок шины на
+// сам шаблон pattern. Подписки на другие шаблоны, совпадающие с субъектом,
+// получают сообщение из собственных подписок брокера.
+func (r *remoteStreams) deliver(s *remoteStream, pattern string, tokens []string, wildcard bool) TransportHandler {
+	sp := r.bus
+	return func(msg *Message, reply string) {
+		if s.released.Load() {
+			return
+		}
+		env := &envelope{
+			subject:   msg.Subject,
+			msg:       msg.Payload,
+			reply:     reply,
+			published: msg.Timestamp.UnixNano(),
+			sequence:  msg.Sequence,
+			headers:   msg.Headers,
+		}
+		if env.subject == "" {
+			env.subject = pattern
+		}
+		if msg.Timestamp.IsZero() {
+			env.published = time.Now().UnixNano()
+		}
+
+		buf := matchPool.Get().(*[]*subscription)
+		mu := sp.routes.lock(pattern, wildcard)
+		mu.RLock()
+		subscribers := sp.routes.matchPattern(pattern, tokens, wildcard, (*buf)[:0])
+		mu.RUnlock()
+		for _, sub := range subscribers {
+			sub.enqueue(env)
+		}
+
+		clear(subscribers)
+		*buf = subscribers[:0]
+		matchPool.Put(buf)
+	}
+}
+
+// publish отправляет сообщение брокеру. Число получателей шине неизвестно,
+// поэтому возвращается -1.
+func (r *remoteStreams) publish(env *envelope) (int, error) {
+	if r.closing.Load() {
+		return 0, ErrClosed
+	}
+	if err := r.transport.Publish(env.message(), env.reply); err != nil {
+		return 0, err
+	}
+	if o := r.bus.observer; o != nil {
+		o.OnPublish(env.subject, -1)
+	}
+	return -1, nil
+}
+
+// close прекращает публикации и ждёт, пока подписки брокера получат
+// сообщения, опубликованные до закрытия. Выполняется один раз.
+func (r *remoteStreams) close(ctx context.Context) {
+	if !r.closing.CompareAndSwap(false, true) {
+		return
+	}
+	if err := r.transport.Close(ctx); err != nil && ctx.Err() == nil {
+		r.bus.reportError(err)
+	}
+}
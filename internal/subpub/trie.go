@@ -0,0 +1,123 @@
+package subpub
+
+// trie — префиксное дерево подписок с подстановочными токенами.
+// Поиск получателей выполняется за время, зависящее от длины субъекта
+// и числа совпавших шаблонов, а не от общего числа подписок.
+// Не потокобезопасно, синхронизация лежит на владельце.
+type trie struct {
+	root *trieNode
+	size int
+}
+
+type trieNode struct {
+	subscriberSet
+	children map[string]*trieNode
+}
+
+func newTrie() *trie {
+	return &trie{root: newTrieNode()}
+}
+
+func newTrieNode() *trieNode {
+	return &trieNode{children: make(map[string]*trieNode)}
+}
+
+func (n *trieNode) empty() bool {
+	return len(n.children) == 0 && n.subscriberSet.empty()
+}
+
+func (t *trie) insert(tokens []string, sub *subscription) {
+	n := t.root
+	for _, token := range tokens {
+		child, ok := n.children[token]
+		if !ok {
+			child = newTrieNode()
+			n.children[token] = child
+		}
+		n = child
+	}
+	n.add(sub)
+	t.size++
+}
+
+// remove удаляет подписку и освобождает опустевшие узлы.
+// Для участника группы очередей также возвращается его группа, если в ней
+// остались другие участники.
+func (t *trie) remove(tokens []string, sub *subscription) (bool, *queueGroup) {
+	path := make([]*trieNode, 0, len(tokens)+1)
+	n := t.root
+	path = append(path, n)
+	for _, token := range tokens {
+		child, ok := n.children[token]
+		if !ok {
+			return false, nil
+		}
+		n = child
+		path = append(path, n)
+	}
+
+	ok, group := n.remove(sub)
+	if !ok {
+		return false, nil
+	}
+	t.size--
+
+	for i := len(tokens) - 1; i >= 0; i-- {
+		if !path[i+1].empty() {
+			break
+		}
+		delete(path[i].children, tokens[i])
+	}
+	return true, group
+}
+
+// find возвращает узел шаблона или nil, если такого узла нет.
+func (t *trie) find(tokens []string) *trieNode {
+	n := t.root
+	for _, token := range tokens {
+		child, ok := n.children[token]
+		if !ok {
+			return nil
+		}
+		n = child
+	}
+	return n
+}
+
+// match добавляет к dst все подписки, чьи шаблоны совпадают с субъектом,
+// и по одному участнику от каждой совпавшей группы очередей.
+// Каждая подписка хранится ровно в одном узле, поэтому попадает в результат не более одного раза.
+func (t *trie) match(tokens []string, dst []*subscription) []*subscription {
+	return t.root.match(tokens, dst)
+}
+
+func (n *trieNode) match(tokens []string, dst []*subscription) []*subscription {
+	if len(tokens) == 0 {
+		return n.collect(dst)
+	}
+
+	if child, ok := n.children[tokens[0]]; ok {
+		dst = child.match(tokens[1:], dst)
+	}
+	if child, ok := n.children[tokenWildcard]; ok {
+		dst = child.match(tokens[1:], dst)
+	}
+	if child, ok := n.children[tokenTail]; ok {
+		dst = child.collect(dst)
+	}
+	return dst
+}
+
+// walk обходит узлы, на которых есть подписки, передавая fn токены шаблона и набор подписок узла.
+func (t *trie) walk(fn func(tokens []string, set *subscriberSet)) {
+	var visit func(n *trieNode, tokens []string)
+	visit = func(n *trieNode, tokens []string) {
+		if !n.subscriberSet.empty() {
+			fn(tokens, &n.subscriberSet)
+		}
+		for token, child := range n.children {
+			visit(child, append(tokens[:len(tokens):len(tokens)], token))
+		}
+	}
+	visit(t.root, nil)
+}
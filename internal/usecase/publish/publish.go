@@ -10,8 +10,9 @@ import (
 
 // Request represents the publish request
 type Request struct {
-	Key  string
-	Data string
+	Key      string
+	Data     string
+	Metadata map[string]string
 }
 
 // UseCase defines the publish use case
@@ -36,6 +37,7 @@ func New(eventRepo repository.EventRepository, logger *logrus.Logger) UseCase {
 func (uc *publishUseCase) Execute(ctx context.Context, req Request) error {
 	// Create and validate event
 	event := entity.NewEvent(req.Key, req.Data)
+	event.Metadata = req.Metadata
 	if err := event.Validate(); err != nil {
 		uc.logger.WithError(err).WithFields(logrus.Fields{
 			"key":  req.Key,
@@ -3,9 +3,12 @@ package subscribe
 import (
 	"awesomeProject3/internal/domain/entity"
 	"awesomeProject3/internal/domain/errors"
+	"awesomeProject3/internal/domain/filter"
 	"awesomeProject3/internal/domain/repository"
 	"context"
+	"fmt"
 	"github.com/sirupsen/logrus"
+	"time"
 )
 
 // UseCase defines the subscription use case interface
@@ -16,6 +19,20 @@ type UseCase interface {
 // Request represents a subscription request
 type Request struct {
 	Key string
+	// Filter is an optional filter expression, see filter.Parse
+	Filter string
+	// RateLimit and Burst limit the delivery rate in events per second; zero disables the limit
+	RateLimit float64
+	Burst     int
+	// MaxInFlight is the maximum number of events handled concurrently
+	MaxInFlight int
+	// BufferSize, OverflowPolicy and BlockTimeout configure the queue of events
+	// waiting for the callback, see repository.SubscribeOptions
+	BufferSize     int
+	OverflowPolicy repository.OverflowPolicy
+	BlockTimeout   time.Duration
+	// OnDrop is called for every event dropped because the queue is full
+	OnDrop func(event *entity.Event, policy repository.OverflowPolicy)
 }
 
 // subscribeUseCase implements the subscription use case
@@ -38,10 +55,33 @@ func (uc *subscribeUseCase) Execute(ctx context.Context, req Request, callback f
 		return errors.ErrInvalidEventKey
 	}
 
-	uc.logger.WithField("key", req.Key).Info("subscribing to events")
+	opts := repository.SubscribeOptions{
+		RateLimit:   req.RateLimit,
+		Burst:       req.Burst,
+		MaxInFlight: req.MaxInFlight,
+
+		BufferSize:     req.BufferSize,
+		OverflowPolicy: req.OverflowPolicy,
+		BlockTimeout:   req.BlockTimeout,
+		OnDrop:         req.OnDrop,
+	}
+	if req.Filter != "" {
+		f, err := filter.Parse(req.Filter)
+		if err != nil {
+			return fmt.Errorf("%w: %v", errors.ErrInvalidFilter, err)
+		}
+		opts.Filter = f.Match
+	}
+
+	uc.logger.WithFields(logrus.Fields{
+		"key":           req.Key,
+		"filter":        req.Filter,
+		"rate_limit":    req.RateLimit,
+		"max_in_flight": req.MaxInFlight,
+	}).Info("subscribing to events")
 
 	// Subscribe to events
-	err := uc.eventRepo.Subscribe(ctx, req.Key, callback)
+	err := uc.eventRepo.Subscribe(ctx, req.Key, opts, callback)
 	if err != nil {
 		uc.logger.WithError(err).Error("failed to subscribe to events")
 		return err
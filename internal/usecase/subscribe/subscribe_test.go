@@ -0,0 +1,82 @@
+package subscribe
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"awesomeProject3/internal/domain/entity"
+	domainerrors "awesomeProject3/internal/domain/errors"
+	"awesomeProject3/internal/domain/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// recordingRepository records the options passed to Subscribe
+type recordingRepository struct {
+	repository.EventRepository
+	calls int
+	opts  repository.SubscribeOptions
+}
+
+func (r *recordingRepository) Subscribe(_ context.Context, _ string, opts repository.SubscribeOptions, _ func(*entity.Event)) error {
+	r.calls++
+	r.opts = opts
+	return nil
+}
+
+func newTestUseCase() (UseCase, *recordingRepository) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	repo := &recordingRepository{}
+	return New(repo, logger), repo
+}
+
+func TestExecuteInvalidFilter(t *testing.T) {
+	for _, expr := range []string{`data ==`, `size == "1"`, `(key == "a"`, `key matches "["`} {
+		uc, repo := newTestUseCase()
+		err := uc.Execute(context.Background(), Request{Key: "orders", Filter: expr}, func(*entity.Event) {})
+		if !errors.Is(err, domainerrors.ErrInvalidFilter) {
+			t.Errorf("filter %q: got %v, want %v", expr, err, domainerrors.ErrInvalidFilter)
+		}
+		if repo.calls != 0 {
+			t.Errorf("filter %q: repository Subscribe called %d times, want 0", expr, repo.calls)
+		}
+	}
+}
+
+func TestExecuteFilter(t *testing.T) {
+	uc, repo := newTestUseCase()
+	req := Request{Key: "orders", Filter: `metadata.region == "eu" && data contains "urgent"`}
+	if err := uc.Execute(context.Background(), req, func(*entity.Event) {}); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if repo.opts.Filter == nil {
+		t.Fatal("Filter was not passed to the repository")
+	}
+
+	tests := []struct {
+		event *entity.Event
+		want  bool
+	}{
+		{&entity.Event{Data: "urgent", Metadata: map[string]string{"region": "eu"}}, true},
+		{&entity.Event{Data: "urgent", Metadata: map[string]string{"region": "us"}}, false},
+		{&entity.Event{Data: "later", Metadata: map[string]string{"region": "eu"}}, false},
+		{&entity.Event{Data: "urgent"}, false},
+	}
+	for _, tt := range tests {
+		if got := repo.opts.Filter(tt.event); got != tt.want {
+			t.Errorf("Filter(%q, %v) = %v, want %v", tt.event.Data, tt.event.Metadata, got, tt.want)
+		}
+	}
+}
+
+func TestExecuteWithoutFilter(t *testing.T) {
+	uc, repo := newTestUseCase()
+	if err := uc.Execute(context.Background(), Request{Key: "orders"}, func(*entity.Event) {}); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if repo.opts.Filter != nil {
+		t.Error("Filter should be nil when no expression is given")
+	}
+}
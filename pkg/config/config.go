@@ -7,6 +7,40 @@ import (
 	"time"
 )
 
+// Duration is a time.Duration that is read from JSON either as a string
+// accepted by time.ParseDuration ("30s", "5m", "100ms") or as a number of
+// nanoseconds
+type Duration struct {
+	time.Duration
+}
+
+// MarshalJSON encodes the duration as a string such as "1m30s"
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON decodes a duration string or a number of nanoseconds
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	switch v := value.(type) {
+	case string:
+		duration, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q: %w", v, err)
+		}
+		d.Duration = duration
+	case float64:
+		d.Duration = time.Duration(v)
+	default:
+		return fmt.Errorf("invalid duration: %s", data)
+	}
+	return nil
+}
+
 // Config represents the application configuration
 type Config struct {
 	Server ServerConfig `json:"server"`
@@ -23,7 +57,7 @@ type ServerConfig struct {
 	Host string `json:"host" validate:"required"`
 	
 	// GracefulShutdownTimeout is the maximum time to wait for graceful shutdown
-	GracefulShutdownTimeout time.Duration `json:"graceful_shutdown_timeout" validate:"required"`
+	GracefulShutdownTimeout Duration `json:"graceful_shutdown_timeout" validate:"required"`
 	
 	// MaxConcurrentStreams is the maximum number of concurrent streams per connection
 	MaxConcurrentStreams uint32 `json:"max_concurrent_streams" validate:"required,min=1"`
@@ -56,7 +90,17 @@ type PubSubConfig struct {
 	MessageBufferSize int `json:"message_buffer_size" validate:"required,min=1"`
 	
 	// CleanupInterval is the interval for cleaning up inactive subscribers
-	CleanupInterval time.Duration `json:"cleanup_interval" validate:"required"`
+	CleanupInterval Duration `json:"cleanup_interval" validate:"required"`
+
+	// OverflowPolicy is what happens when a subscriber buffer is full
+	// (drop_newest, drop_oldest, block, disconnect)
+	OverflowPolicy string `json:"overflow_policy" validate:"oneof=drop_newest drop_oldest block disconnect"`
+
+	// BlockTimeout is how long a publisher waits for buffer space with the block policy
+	BlockTimeout Duration `json:"block_timeout"`
+
+	// MaxInFlight is the largest max_in_flight a subscriber may request
+	MaxInFlight int `json:"max_in_flight"`
 }
 
 // Load loads configuration from a file
@@ -85,7 +129,7 @@ func Default() *Config {
 		Server: ServerConfig{
 			Port:                  8080,
 			Host:                  "0.0.0.0",
-			GracefulShutdownTimeout: 30 * time.Second,
+			GracefulShutdownTimeout: Duration{30 * time.Second},
 			MaxConcurrentStreams:  100,
 		},
 		Log: LogConfig{
@@ -98,7 +142,10 @@ func Default() *Config {
 		PubSub: PubSubConfig{
 			MaxSubscribersPerKey: 1000,
 			MessageBufferSize:    100,
-			CleanupInterval:      5 * time.Minute,
+			CleanupInterval:      Duration{5 * time.Minute},
+			OverflowPolicy:       "drop_newest",
+			BlockTimeout:         Duration{100 * time.Millisecond},
+			MaxInFlight:          64,
 		},
 	}
 }
@@ -113,7 +160,7 @@ func (c *Config) validate() error {
 		return fmt.Errorf("server host is required")
 	}
 
-	if c.Server.GracefulShutdownTimeout <= 0 {
+	if c.Server.GracefulShutdownTimeout.Duration <= 0 {
 		return fmt.Errorf("graceful shutdown timeout must be positive")
 	}
 
@@ -145,9 +192,23 @@ func (c *Config) validate() error {
 		return fmt.Errorf("message buffer size must be positive")
 	}
 
-	if c.PubSub.CleanupInterval <= 0 {
+	if c.PubSub.CleanupInterval.Duration <= 0 {
 		return fmt.Errorf("cleanup interval must be positive")
 	}
 
+	switch c.PubSub.OverflowPolicy {
+	case "", "drop_newest", "drop_oldest", "block", "disconnect":
+	default:
+		return fmt.Errorf("invalid overflow policy: %s", c.PubSub.OverflowPolicy)
+	}
+
+	if c.PubSub.OverflowPolicy == "block" && c.PubSub.BlockTimeout.Duration <= 0 {
+		return fmt.Errorf("block timeout must be positive")
+	}
+
+	if c.PubSub.MaxInFlight < 0 {
+		return fmt.Errorf("max in flight must not be negative")
+	}
+
 	return nil
 } 
\ No newline at end of file
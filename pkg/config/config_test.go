@@ -0,0 +1,84 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadShippedConfig(t *testing.T) {
+	cfg, err := Load(filepath.Join("..", "..", "config.json"))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	// The shipped config.json spells out the defaults
+	if want := Default(); !reflect.DeepEqual(cfg, want) {
+		t.Errorf("Load = %+v, want %+v", cfg, want)
+	}
+}
+
+func TestLoadInvalidDuration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	data := `{"server": {"port": 8080, "host": "0.0.0.0", "graceful_shutdown_timeout": "30 seconds"}}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	_, err := Load(path)
+	if err == nil || !strings.Contains(err.Error(), `invalid duration "30 seconds"`) {
+		t.Errorf("Load: got %v, want an invalid duration error", err)
+	}
+}
+
+func TestDurationUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		data string
+		want time.Duration
+		ok   bool
+	}{
+		{`"30s"`, 30 * time.Second, true},
+		{`"5m"`, 5 * time.Minute, true},
+		{`"1h30m"`, 90 * time.Minute, true},
+		{`"100ms"`, 100 * time.Millisecond, true},
+		{`1000000`, time.Millisecond, true},
+		{`"30"`, 0, false},
+		{`"soon"`, 0, false},
+		{`true`, 0, false},
+		{`["1s"]`, 0, false},
+	}
+
+	for _, tt := range tests {
+		var d Duration
+		err := json.Unmarshal([]byte(tt.data), &d)
+		if (err == nil) != tt.ok {
+			t.Errorf("Unmarshal(%s): err = %v, want ok = %v", tt.data, err, tt.ok)
+			continue
+		}
+		if d.Duration != tt.want {
+			t.Errorf("Unmarshal(%s) = %v, want %v", tt.data, d.Duration, tt.want)
+		}
+	}
+}
+
+func TestDurationRoundTrip(t *testing.T) {
+	data, err := json.Marshal(Duration{90 * time.Second})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if string(data) != `"1m30s"` {
+		t.Errorf("Marshal = %s, want %q", data, "1m30s")
+	}
+
+	var d Duration
+	if err := json.Unmarshal(data, &d); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if d.Duration != 90*time.Second {
+		t.Errorf("Unmarshal = %v, want %v", d.Duration, 90*time.Second)
+	}
+}
@@ -25,7 +25,11 @@ const (
 // SubscribeRequest содержит параметры для подписки
 type SubscribeRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"` // ключ для подписки
+	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`                                       // ключ для подписки
+	Filter        string                 `protobuf:"bytes,2,opt,name=filter,proto3" json:"filter,omitempty"`                                 // выражение фильтра по данным и метаданным события
+	RateLimit     float64                `protobuf:"fixed64,3,opt,name=rate_limit,json=rateLimit,proto3" json:"rate_limit,omitempty"`        // ограничение скорости доставки, событий в секунду
+	Burst         uint32                 `protobuf:"varint,4,opt,name=burst,proto3" json:"burst,omitempty"`                                  // допустимый всплеск при ограничении скорости
+	MaxInFlight   uint32                 `protobuf:"varint,5,opt,name=max_in_flight,json=maxInFlight,proto3" json:"max_in_flight,omitempty"` // предел числа одновременно обрабатываемых событий; поток всегда получает события по одному в порядке публикации
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -67,11 +71,40 @@ func (x *SubscribeRequest) GetKey() string {
 	return ""
 }
 
+func (x *SubscribeRequest) GetFilter() string {
+	if x != nil {
+		return x.Filter
+	}
+	return ""
+}
+
+func (x *SubscribeRequest) GetRateLimit() float64 {
+	if x != nil {
+		return x.RateLimit
+	}
+	return 0
+}
+
+func (x *SubscribeRequest) GetBurst() uint32 {
+	if x != nil {
+		return x.Burst
+	}
+	return 0
+}
+
+func (x *SubscribeRequest) GetMaxInFlight() uint32 {
+	if x != nil {
+		return x.MaxInFlight
+	}
+	return 0
+}
+
 // PublishRequest содержит параметры для публикации
 type PublishRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`   // ключ для публикации
-	Data          string                 `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"` // данные сообщения
+	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`                                                                                     // ключ для публикации
+	Data          string                 `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`                                                                                   // данные сообщения
+	Metadata      map[string]string      `protobuf:"bytes,3,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // метаданные сообщения
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -120,10 +153,19 @@ func (x *PublishRequest) GetData() string {
 	return ""
 }
 
+func (x *PublishRequest) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
 // Event представляет событие, отправляемое подписчику
 type Event struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Data          string                 `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"` // данные события
+	Data          string                 `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`                                                                                   // данные события
+	Metadata      map[string]string      `protobuf:"bytes,2,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // метаданные события
+	Key           string                 `protobuf:"bytes,3,opt,name=key,proto3" json:"key,omitempty"`                                                                                     // ключ, в который было опубликовано событие
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -165,18 +207,46 @@ func (x *Event) GetData() string {
 	return ""
 }
 
+func (x *Event) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *Event) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
 var File_internal_pubsub_proto_pubsub_proto protoreflect.FileDescriptor
 
 const file_internal_pubsub_proto_pubsub_proto_rawDesc = "" +
 	"\n" +
-	"\"internal/pubsub/proto/pubsub.proto\x12\x06pubsub\x1a\x1bgoogle/protobuf/empty.proto\"$\n" +
+	"\"internal/pubsub/proto/pubsub.proto\x12\x06pubsub\x1a\x1bgoogle/protobuf/empty.proto\"\x95\x01\n" +
 	"\x10SubscribeRequest\x12\x10\n" +
-	"\x03key\x18\x01 \x01(\tR\x03key\"6\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x16\n" +
+	"\x06filter\x18\x02 \x01(\tR\x06filter\x12\x1d\n" +
+	"\n" +
+	"rate_limit\x18\x03 \x01(\x01R\trateLimit\x12\x14\n" +
+	"\x05burst\x18\x04 \x01(\rR\x05burst\x12\"\n" +
+	"\rmax_in_flight\x18\x05 \x01(\rR\vmaxInFlight\"\xb5\x01\n" +
 	"\x0ePublishRequest\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x12\n" +
-	"\x04data\x18\x02 \x01(\tR\x04data\"\x1b\n" +
+	"\x04data\x18\x02 \x01(\tR\x04data\x12@\n" +
+	"\bmetadata\x18\x03 \x03(\v2$.pubsub.PublishRequest.MetadataEntryR\bmetadata\x1a;\n" +
+	"\rMetadataEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xa3\x01\n" +
 	"\x05Event\x12\x12\n" +
-	"\x04data\x18\x01 \x01(\tR\x04data2\x7f\n" +
+	"\x04data\x18\x01 \x01(\tR\x04data\x127\n" +
+	"\bmetadata\x18\x02 \x03(\v2\x1b.pubsub.Event.MetadataEntryR\bmetadata\x12\x10\n" +
+	"\x03key\x18\x03 \x01(\tR\x03key\x1a;\n" +
+	"\rMetadataEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x012\x7f\n" +
 	"\x06PubSub\x128\n" +
 	"\tSubscribe\x12\x18.pubsub.SubscribeRequest\x1a\r.pubsub.Event\"\x000\x01\x12;\n" +
 	"\aPublish\x12\x16.pubsub.PublishRequest\x1a\x16.google.protobuf.Empty\"\x00B\x1eZ\x1cpubsub/internal/pubsub/protob\x06proto3"
@@ -193,23 +263,27 @@ func file_internal_pubsub_proto_pubsub_proto_rawDescGZIP() []byte {
 	return file_internal_pubsub_proto_pubsub_proto_rawDescData
 }
 
-var file_internal_pubsub_proto_pubsub_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_internal_pubsub_proto_pubsub_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
 var file_internal_pubsub_proto_pubsub_proto_goTypes = []any{
 	(*SubscribeRequest)(nil), // 0: pubsub.SubscribeRequest
 	(*PublishRequest)(nil),   // 1: pubsub.PublishRequest
 	(*Event)(nil),            // 2: pubsub.Event
-	(*emptypb.Empty)(nil),    // 3: google.protobuf.Empty
+	nil,                      // 3: pubsub.PublishRequest.MetadataEntry
+	nil,                      // 4: pubsub.Event.MetadataEntry
+	(*emptypb.Empty)(nil),    // 5: google.protobuf.Empty
 }
 var file_internal_pubsub_proto_pubsub_proto_depIdxs = []int32{
-	0, // 0: pubsub.PubSub.Subscribe:input_type -> pubsub.SubscribeRequest
-	1, // 1: pubsub.PubSub.Publish:input_type -> pubsub.PublishRequest
-	2, // 2: pubsub.PubSub.Subscribe:output_type -> pubsub.Event
-	3, // 3: pubsub.PubSub.Publish:output_type -> google.protobuf.Empty
-	2, // [2:4] is the sub-list for method output_type
-	0, // [0:2] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	3, // 0: pubsub.PublishRequest.metadata:type_name -> pubsub.PublishRequest.MetadataEntry
+	4, // 1: pubsub.Event.metadata:type_name -> pubsub.Event.MetadataEntry
+	0, // 2: pubsub.PubSub.Subscribe:input_type -> pubsub.SubscribeRequest
+	1, // 3: pubsub.PubSub.Publish:input_type -> pubsub.PublishRequest
+	2, // 4: pubsub.PubSub.Subscribe:output_type -> pubsub.Event
+	5, // 5: pubsub.PubSub.Publish:output_type -> google.protobuf.Empty
+	4, // [4:6] is the sub-list for method output_type
+	2, // [2:4] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
 }
 
 func init() { file_internal_pubsub_proto_pubsub_proto_init() }
@@ -223,7 +297,7 @@ func file_internal_pubsub_proto_pubsub_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_internal_pubsub_proto_pubsub_proto_rawDesc), len(file_internal_pubsub_proto_pubsub_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   3,
+			NumMessages:   5,
 			NumExtensions: 0,
 			NumServices:   1,
 		},